@@ -0,0 +1,63 @@
+package mysqldump
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path"
+)
+
+// Name of the member describing the files of an archived dump, see WithArchive.
+const archiveManifestName = "manifest.json"
+
+// Bundles the files of a dump with WithFilePerTable into a single
+// '<format>.tar' instead of a directory, to move it around as one file. The
+// archive holds the files in restore order followed by manifest.json, which
+// lists each file with its table, size, rows and checksum (see WithChecksums).
+// Files are compressed and encrypted on their own as usual, the archive
+// itself is not.
+func WithArchive(enabled bool) Option {
+	return func(d *Dumper) {
+		d.archive = enabled
+	}
+}
+
+// Moves a finished file of the dump from the directory into the archive.
+func (f *tableFiles) moveToArchive(file string) error {
+	p := path.Join(f.dir, file)
+	in, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := &tar.Header{Name: file, Mode: int64(f.d.fileMode), Size: info.Size(), ModTime: f.d.now()}
+	if err = f.archive.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err = io.Copy(f.archive, in); err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+// Writes manifest.json to the archive and finishes it.
+func (f *tableFiles) closeArchive() error {
+	data, err := f.index()
+	if err != nil {
+		return err
+	}
+
+	header := &tar.Header{Name: archiveManifestName, Mode: int64(f.d.fileMode), Size: int64(len(data)), ModTime: f.d.now()}
+	if err = f.archive.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err = f.archive.Write(data); err != nil {
+		return err
+	}
+	return f.archive.Close()
+}
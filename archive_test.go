@@ -0,0 +1,66 @@
+package mysqldump
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestArchive(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	f.addTable("other", []string{"id"}, []string{"INT"}, fakeRow(int64(1)))
+	d := newTestDumper(t, f, WithFilePerTable(true), WithArchive(true))
+	if err := d.Dump(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "dump.tar" {
+		t.Fatalf("dump directory holds %v, want dump.tar", entries)
+	}
+
+	archive, err := os.Open(path.Join(d.dir, "dump.tar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	tr := tar.NewReader(archive)
+	var names []string
+	var manifest fileIndex
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+		if header.Mode != 0600 {
+			t.Errorf("%s has mode %o, want 0600", header.Name, header.Mode)
+		}
+		if header.Name == archiveManifestName {
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	want := []string{"db.users.sql", "db.other.sql", "dump.sql", archiveManifestName}
+	if len(names) != len(want) {
+		t.Fatalf("archive holds %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("archive holds %v, want %v", names, want)
+		}
+	}
+	if len(manifest.Files) != 3 || manifest.Files[0].Rows != 2 || manifest.Files[1].Table != "other" {
+		t.Errorf("manifest lists %+v", manifest.Files)
+	}
+}
@@ -0,0 +1,39 @@
+package mysqldump
+
+import "regexp"
+
+// How the AUTO_INCREMENT table option is dumped, see WithAutoIncrement.
+type AutoIncrement int
+
+const (
+	AutoIncrementPreserve AutoIncrement = iota // Keep the table's current counter
+	AutoIncrementReset                         // Restart the counter at 1
+	AutoIncrementStrip                         // Remove the option, restored tables start at 1
+)
+
+var autoIncrementOption = regexp.MustCompile(" AUTO_INCREMENT=[0-9]+")
+
+// Sets how the AUTO_INCREMENT=n table option of CREATE TABLE statements is
+// dumped. Defaults to AutoIncrementPreserve.
+func WithAutoIncrement(mode AutoIncrement) Option {
+	return func(d *Dumper) {
+		d.autoIncrement = mode
+	}
+}
+
+// Rewrites or removes the AUTO_INCREMENT table option of a CREATE TABLE statement.
+func (d *Dumper) rewriteAutoIncrement(table_sql string) string {
+	if d.autoIncrement == AutoIncrementPreserve {
+		return table_sql
+	}
+
+	before, options, after := splitTableOptions(table_sql)
+	replacement := ""
+	if d.autoIncrement == AutoIncrementReset {
+		replacement = " AUTO_INCREMENT=1"
+	}
+	if loc := autoIncrementOption.FindStringIndex(options); loc != nil {
+		options = options[:loc[0]] + replacement + options[loc[1]:]
+	}
+	return before + options + after
+}
@@ -0,0 +1,28 @@
+package mysqldump
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAutoIncrement(t *testing.T) {
+	tests := []struct {
+		mode AutoIncrement
+		want string
+	}{
+		{AutoIncrementPreserve, ") ENGINE=InnoDB AUTO_INCREMENT=42 DEFAULT CHARSET=utf8mb4;\n"},
+		{AutoIncrementReset, ") ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8mb4;\n"},
+		{AutoIncrementStrip, ") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n"},
+	}
+	for _, test := range tests {
+		f := newFakeDB()
+		f.addUsers()
+		// A column comment looking like the option is kept
+		f.set("SHOW CREATE TABLE `db`.`users`", []string{"Table", "Create Table"}, fakeRow("users",
+			"CREATE TABLE `users` (\n  `id` int NOT NULL AUTO_INCREMENT COMMENT ' AUTO_INCREMENT=7'\n) ENGINE=InnoDB AUTO_INCREMENT=42 DEFAULT CHARSET=utf8mb4"))
+		got := dumpString(t, f, WithAutoIncrement(test.mode))
+		if !strings.Contains(got, "COMMENT ' AUTO_INCREMENT=7'\n"+test.want) {
+			t.Errorf("dump with mode %d doesn't have %q:\n%s", test.mode, test.want, got)
+		}
+	}
+}
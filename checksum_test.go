@@ -0,0 +1,66 @@
+package mysqldump
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestRowChecksum(t *testing.T) {
+	sum := func(rows ...[]sql.RawBytes) string {
+		c := newRowChecksum()
+		for _, row := range rows {
+			c.add(row)
+		}
+		return c.String()
+	}
+	a := []sql.RawBytes{sql.RawBytes("1"), sql.RawBytes("a")}
+	b := []sql.RawBytes{sql.RawBytes("2"), nil}
+
+	if sum(a, b) != sum(b, a) {
+		t.Error("checksum depends on the row order")
+	}
+	tests := []struct {
+		name string
+		rows [][]sql.RawBytes
+	}{
+		{"one row", [][]sql.RawBytes{a}},
+		{"NULL instead of empty", [][]sql.RawBytes{{sql.RawBytes("2"), sql.RawBytes{}}, a}},
+		{"values moved between columns", [][]sql.RawBytes{{sql.RawBytes("1a"), sql.RawBytes("")}, b}},
+		{"duplicated row", [][]sql.RawBytes{a, b, b}},
+	}
+	for _, test := range tests {
+		if sum(test.rows...) == sum(a, b) {
+			t.Errorf("%s has the same checksum", test.name)
+		}
+	}
+	if got := sum(); got != "0000000000000000" {
+		t.Errorf("empty checksum is %s", got)
+	}
+}
+
+func TestChecksumLine(t *testing.T) {
+	tests := []TableStats{
+		{Database: "db", Table: "users", Rows: 2, Checksum: "00ff00ff00ff00ff"},
+		{Database: "my db", Table: "a%b/c", Rows: 0, Checksum: "0000000000000000"},
+		{Database: "a.b", Table: "c", Rows: 1, Checksum: "0000000000000001"},
+		{Database: "a", Table: "b.c", Rows: 1, Checksum: "0000000000000002"},
+	}
+	for _, stats := range tests {
+		line := checksumLine(stats)
+		got, err := parseChecksumLine(line)
+		if err != nil {
+			t.Fatalf("parsing %q: %v", line, err)
+		}
+		if got != stats {
+			t.Errorf("line %q parses to %+v, want %+v", line, got, stats)
+		}
+	}
+	if checksumLine(tests[2]) == checksumLine(TableStats{Database: "a", Table: "b.c", Rows: 1, Checksum: "0000000000000001"}) {
+		t.Error("names with dots have the same checksum line")
+	}
+	for _, line := range []string{"", "db.users 2", "users 2 00ff", "db.users two 00ff", "db.%zz 2 00ff"} {
+		if _, err := parseChecksumLine(line); err == nil {
+			t.Errorf("parsing %q succeeded", line)
+		}
+	}
+}
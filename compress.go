@@ -0,0 +1,123 @@
+package mysqldump
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// Compressor compresses dumps, see WithCompressor.
+type Compressor interface {
+	// Returns a writer compressing to w. Closing it must flush the
+	// compressed data without closing w.
+	Wrap(w io.Writer) (io.WriteCloser, error)
+	// Suffix added to '.sql' in the names of dump files, such as '.gz'.
+	Extension() string
+}
+
+// Compresses dumps with c, or not at all when c is nil. Every file of a dump
+// is compressed on its own. Only gzip is built in, see Gzip; other formats
+// need an encoder from another package, see Zstd.
+func WithCompressor(c Compressor) Option {
+	return func(d *Dumper) {
+		d.compressor = c
+	}
+}
+
+// Returns a Compressor for gzip at the given level (see compress/gzip),
+// naming files '.sql.gz'.
+func Gzip(level int) Compressor {
+	return gzipCompressor{level}
+}
+
+type gzipCompressor struct {
+	level int
+}
+
+func (c gzipCompressor) Wrap(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, c.level)
+}
+
+func (gzipCompressor) Extension() string {
+	return ".gz"
+}
+
+/*
+Returns a Compressor naming files '.sql.zst' for a zstd encoder supplied by the
+caller. This package has no zstd encoder of its own, as the standard library
+has none, so newWriter must create one from a zstd package. For example with
+github.com/klauspost/compress/zstd:
+
+	mysqldump.Zstd(func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w)
+	})
+
+Verify can't read zstd compressed dumps.
+*/
+func Zstd(newWriter func(w io.Writer) (io.WriteCloser, error)) Compressor {
+	return zstdCompressor{newWriter}
+}
+
+type zstdCompressor struct {
+	newWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+func (c zstdCompressor) Wrap(w io.Writer) (io.WriteCloser, error) {
+	return c.newWriter(w)
+}
+
+func (zstdCompressor) Extension() string {
+	return ".zst"
+}
+
+func (d *Dumper) extension() string {
+	ext := d.outputFormat.extension()
+	if d.compressor != nil {
+		ext += d.compressor.Extension()
+	}
+	if d.encryptionKey != nil {
+		ext += ".enc"
+	}
+	return ext
+}
+
+// Wraps w to compress and then encrypt what is written, when enabled.
+// Closing flushes everything to w but does not close w.
+func (d *Dumper) encodeWriter(w io.Writer) (io.WriteCloser, error) {
+	var encrypted io.WriteCloser = nopCloser{w}
+	if d.encryptionKey != nil {
+		var err error
+		if encrypted, err = newEncryptWriter(w, d.encryptionKey); err != nil {
+			return nil, err
+		}
+	}
+	if d.compressor == nil {
+		return encrypted, nil
+	}
+	compressed, err := d.compressor.Wrap(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	return &chainCloser{compressed, encrypted}, nil
+}
+
+// Closes a writer and then the writer it writes to.
+type chainCloser struct {
+	io.WriteCloser
+	next io.Closer
+}
+
+func (c *chainCloser) Close() error {
+	err := c.WriteCloser.Close()
+	if cerr := c.next.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
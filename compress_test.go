@@ -0,0 +1,94 @@
+package mysqldump
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestCompressedDump(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		file string
+	}{
+		{"gzip", []Option{WithCompression(gzip.BestSpeed)}, "dump.sql.gz"},
+		{"gzip compressor", []Option{WithCompressor(Gzip(gzip.DefaultCompression))}, "dump.sql.gz"},
+		{"json gzip", []Option{WithCompression(gzip.BestSpeed), WithOutputFormat(FormatJSON)}, "dump.jsonl.gz"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeDB()
+			f.addUsers()
+			d := newTestDumper(t, f, test.opts...)
+			if err := d.Dump(); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path.Join(d.dir, test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			zr, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want bytes.Buffer
+			d.compressor = nil
+			if err := d.DumpTo(&want); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want.Bytes()) {
+				t.Errorf("decompressed dump is:\n%s\nwant:\n%s", got, want.Bytes())
+			}
+		})
+	}
+}
+
+// Compressor that writes its input as is between markers, to see what it was given.
+type markCompressor struct{}
+
+func (markCompressor) Wrap(w io.Writer) (io.WriteCloser, error) {
+	if _, err := io.WriteString(w, "<"); err != nil {
+		return nil, err
+	}
+	return markWriter{w}, nil
+}
+
+func (markCompressor) Extension() string {
+	return ".mark"
+}
+
+type markWriter struct {
+	io.Writer
+}
+
+func (m markWriter) Close() error {
+	_, err := io.WriteString(m.Writer, ">")
+	return err
+}
+
+func TestCustomCompressor(t *testing.T) {
+	for _, c := range []Compressor{markCompressor{}, Zstd(markCompressor{}.Wrap)} {
+		f := newFakeDB()
+		f.addUsers()
+		var b bytes.Buffer
+		d := newTestDumper(t, f, WithCompressor(c))
+		if err := d.DumpTo(&b); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != "<"+wantDump+">" {
+			t.Errorf("%T wrote %q", c, got)
+		}
+		if got, want := d.extension(), ".sql"+c.Extension(); got != want {
+			t.Errorf("extension = %q, want %q", got, want)
+		}
+	}
+}
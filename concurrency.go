@@ -0,0 +1,103 @@
+package mysqldump
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+type tableResult struct {
+	buf   bytes.Buffer
+	stats TableStats
+	err   error
+}
+
+// Reads tables with a pool of workers, each on its own connection. Every table
+// is rendered into memory and written to w in the original order. No more
+// tables are rendered ahead than there are workers, so a slow table doesn't
+// leave the rest of the dump in memory.
+func (d *Dumper) writeTablesConcurrently(ctx context.Context, w io.Writer, schema string, tables []string, run *dumpRun) error {
+	// Stop the workers when returning early, and wait for them
+	ctx, cancel := context.WithCancel(ctx)
+	var workersDone sync.WaitGroup
+	defer func() {
+		cancel()
+		workersDone.Wait()
+	}()
+
+	jobs := make(chan int, len(tables))
+	for i := range tables {
+		jobs <- i
+	}
+	close(jobs)
+
+	results := make([]chan *tableResult, len(tables))
+	for i := range results {
+		results[i] = make(chan *tableResult, 1)
+	}
+
+	// Holds a slot for each table taken by a worker and not yet written
+	pending := make(chan struct{}, d.concurrency)
+
+	workers := d.concurrency
+	if workers > len(tables) {
+		workers = len(tables)
+	}
+	workersDone.Add(workers)
+	for n := 0; n < workers; n++ {
+		go func() {
+			defer workersDone.Done()
+			conn, err := d.openConn(ctx)
+			if err == nil {
+				defer conn.Close()
+			}
+			for {
+				select {
+				case pending <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				i, ok := <-jobs
+				if !ok {
+					return
+				}
+				r := &tableResult{err: err}
+				if r.err == nil {
+					r.stats, r.err = d.writeTable(ctx, conn, &r.buf, run, schema, tables[i], i, len(tables))
+				}
+				results[i] <- r
+			}
+		}()
+	}
+
+	// Write tables in order as they complete
+	for i := range tables {
+		var r *tableResult
+		select {
+		case r = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		<-pending
+		// Failed tables are only rendered into memory, so they can always be skipped
+		if r.err != nil {
+			if err := d.skipTable(ctx, w, run, schema, tables[i], 0, r.err); err != nil {
+				return err
+			}
+			continue
+		}
+		err := run.write(w, schema, tables[i], func(w io.Writer) error {
+			_, err := r.buf.WriteTo(w)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		run.addTable(r.stats)
+		if err = run.checkpoint(r.stats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
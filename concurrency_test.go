@@ -0,0 +1,76 @@
+package mysqldump
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrency(t *testing.T) {
+	f := newFakeDB()
+	for i := 0; i < 8; i++ {
+		addLargeTable(f, fmt.Sprint("t", i), 50*i)
+	}
+	want := dumpString(t, f)
+	for _, n := range []int{2, 4, 16} {
+		if got := dumpString(t, f, WithConcurrency(n)); got != want {
+			t.Errorf("dump with %d workers differs:\n%s", n, got)
+		}
+	}
+}
+
+func TestConcurrencyWaitsForWorkers(t *testing.T) {
+	f := newFakeDB()
+	addLargeTable(f, "t0", 1)
+	addLargeTable(f, "t1", 1)
+	started := make(chan struct{})
+	var finished atomic.Bool
+	f.handle = func(query string, args []driver.Value) (fakeResult, bool) {
+		switch {
+		case strings.HasPrefix(query, "SELECT `id`, `name`, `data` FROM `db`.`t0`"):
+			<-started
+			return fakeResult{err: errors.New("connection lost")}, true
+		case strings.HasPrefix(query, "SELECT `id`, `name`, `data` FROM `db`.`t1`"):
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			finished.Store(true)
+		}
+		return fakeResult{}, false
+	}
+	var b strings.Builder
+	if err := newTestDumper(t, f, WithConcurrency(2)).DumpTo(&b); err == nil {
+		t.Fatal("DumpTo succeeded")
+	}
+	if !finished.Load() {
+		t.Error("DumpTo returned before its workers")
+	}
+}
+
+func TestConcurrencyBoundsRunAhead(t *testing.T) {
+	f := newFakeDB()
+	for i := 0; i < 8; i++ {
+		addLargeTable(f, fmt.Sprint("t", i), 1)
+	}
+	// The first table is slow, the next ones are rendered meanwhile
+	var ahead, during atomic.Int32
+	f.handle = func(query string, args []driver.Value) (fakeResult, bool) {
+		if strings.HasPrefix(query, "SELECT `id`, `name`, `data` FROM `db`.`t0`") {
+			time.Sleep(50 * time.Millisecond)
+			during.Store(ahead.Load())
+		} else if strings.HasPrefix(query, "SELECT `id`, `name`, `data` FROM") {
+			ahead.Add(1)
+		}
+		return fakeResult{}, false
+	}
+	var b strings.Builder
+	if err := newTestDumper(t, f, WithConcurrency(3)).DumpTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if n := during.Load(); n > 2 {
+		t.Errorf("%d tables rendered ahead of the first with 3 workers, want at most 2", n)
+	}
+}
@@ -0,0 +1,63 @@
+package mysqldump
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// A table that was skipped after failing, see WithContinueOnError.
+type TableError struct {
+	Database string
+	Table    string
+	Err      error
+}
+
+func (e *TableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TableError) Unwrap() error {
+	return e.Err
+}
+
+// Skips tables that fail, such as for missing privileges, and dumps the
+// others instead of stopping. A comment marks each skipped table in the dump.
+// The dump is kept, and once it is complete the failures are returned as one
+// error joining a *TableError for each table. They are also listed in
+// Stats.Errors. Tables that fail after some of their rows are written, and
+// cancelled dumps, still stop the dump unless the tables are dumped
+// concurrently, see WithConcurrency.
+func WithContinueOnError(enabled bool) Option {
+	return func(d *Dumper) {
+		d.continueOnError = enabled
+	}
+}
+
+// Data for the "skipped" template.
+type skippedTable struct {
+	Name  string
+	Error string // On a single line
+}
+
+// Records a table that failed before any of it was written, see
+// WithContinueOnError, and marks it in the dump. Returns err when the dump
+// can't continue.
+func (d *Dumper) skipTable(ctx context.Context, w io.Writer, run *dumpRun, schema, name string, written int64, err error) error {
+	if !d.continueOnError || written > 0 || ctx.Err() != nil {
+		return err
+	}
+	run.stats.Errors = append(run.stats.Errors, TableError{Database: schema, Table: name, Err: err})
+	message := strings.Join(strings.Fields(err.Error()), " ")
+	return d.execute(w, "skipped", &skippedTable{Name: name, Error: message})
+}
+
+// Returns the tables skipped by the dump as one error, or nil.
+func (r *dumpRun) failures() error {
+	errs := make([]error, len(r.stats.Errors))
+	for i, _ := range r.stats.Errors {
+		errs[i] = &r.stats.Errors[i]
+	}
+	return errors.Join(errs...)
+}
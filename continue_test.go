@@ -0,0 +1,42 @@
+package mysqldump
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestContinueOnError(t *testing.T) {
+	for _, concurrency := range []int{0, 2} {
+		f := newFakeDB()
+		f.addUsers()
+		f.addTable("secret", []string{"id"}, []string{"INT"})
+		f.addTable("other", []string{"id"}, []string{"INT"}, fakeRow(int64(1)))
+		denied := errors.New("Error 1142: SELECT command denied\nto user")
+		f.fail("SHOW CREATE TABLE `db`.`secret`", denied)
+
+		var b strings.Builder
+		d := newTestDumper(t, f, WithContinueOnError(true), WithConcurrency(concurrency))
+		err := d.DumpTo(&b)
+		var tableErr *TableError
+		if !errors.As(err, &tableErr) || tableErr.Table != "secret" || !errors.Is(err, denied) {
+			t.Fatalf("DumpTo = %v, want a TableError for secret", err)
+		}
+		got := b.String()
+		for _, want := range []string{"INSERT INTO `users`", "-- Skipped table secret: dumping table \"secret\": reading structure: Error 1142: SELECT command denied to user\n", "INSERT INTO `other`"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("dump with concurrency %d doesn't contain %q:\n%s", concurrency, want, got)
+			}
+		}
+	}
+}
+
+func TestStopOnError(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	f.fail("SHOW CREATE TABLE `db`.`users`", errors.New("denied"))
+	var b strings.Builder
+	if err := newTestDumper(t, f).DumpTo(&b); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("DumpTo = %v, want denied", err)
+	}
+}
@@ -0,0 +1,101 @@
+package mysqldump
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Schemas left out when dumping all databases.
+var systemDatabases = map[string]bool{
+	"information_schema": true,
+	"performance_schema": true,
+	"mysql":              true,
+	"sys":                true,
+}
+
+// Dumps the given databases instead of the one selected on the connection,
+// like mysqldump's --databases. Each database is preceded by CREATE DATABASE
+// and USE statements.
+func WithDatabases(names ...string) Option {
+	return func(d *Dumper) {
+		d.databases = append(d.databases, names...)
+	}
+}
+
+// Dumps every database on the server except the system schemas, like
+// mysqldump's --all-databases.
+func WithAllDatabases(enabled bool) Option {
+	return func(d *Dumper) {
+		d.allDatabases = enabled
+	}
+}
+
+// Starts the dump with CREATE DATABASE and USE statements for the database
+// selected on the connection, so it restores into a database of the same name.
+// Always on with WithDatabases and WithAllDatabases. Dumping fails if the
+// connection has no database selected.
+func WithCreateDatabase(enabled bool) Option {
+	return func(d *Dumper) {
+		d.createDatabase = enabled
+	}
+}
+
+func (d *Dumper) multipleDatabases() bool {
+	return d.allDatabases || len(d.databases) > 0
+}
+
+// Lists the databases to dump. Without WithDatabases or WithAllDatabases
+// this is the database selected on the connection.
+func (d *Dumper) getDatabases(ctx context.Context, db Querier) ([]string, error) {
+	if len(d.databases) > 0 {
+		return d.databases, nil
+	}
+	if !d.allDatabases {
+		schema, err := getCurrentDatabase(ctx, db)
+		if err != nil {
+			return nil, err
+		}
+		return []string{schema}, nil
+	}
+
+	rows, err := db.QueryContext(ctx, "SHOW DATABASES")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schemas := make([]string, 0)
+	for rows.Next() {
+		var schema string
+		if err := rows.Scan(&schema); err != nil {
+			return nil, err
+		}
+		if !systemDatabases[schema] {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas, rows.Err()
+}
+
+func getCurrentDatabase(ctx context.Context, db Querier) (string, error) {
+	var schema sql.NullString
+	if err := db.QueryRowContext(ctx, "SELECT DATABASE()").Scan(&schema); err != nil {
+		return "", err
+	}
+	if !schema.Valid {
+		return "", errors.New("No database selected")
+	}
+	return schema.String, nil
+}
+
+func createDatabase(ctx context.Context, db Querier, schema string) (*table, error) {
+	// Get database creation SQL
+	var database_return, database_sql string
+	err := db.QueryRowContext(ctx, "SHOW CREATE DATABASE IF NOT EXISTS "+quoteIdentifier(schema)).Scan(&database_return, &database_sql)
+	if err != nil {
+		return nil, err
+	}
+
+	return &table{Name: schema, SQL: database_sql}, nil
+}
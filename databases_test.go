@@ -0,0 +1,47 @@
+package mysqldump
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Adds a database 'shop' with a table 'orders' of one row.
+func addShop(f *fakeDB) {
+	f.set("SHOW CREATE DATABASE IF NOT EXISTS `shop`", []string{"Database", "Create Database"},
+		fakeRow("shop", "CREATE DATABASE /*!32312 IF NOT EXISTS*/ `shop`"))
+	f.set("SHOW FULL TABLES FROM `shop` WHERE Table_type = 'BASE TABLE'", []string{"Tables_in_shop", "Table_type"},
+		fakeRow("orders", "BASE TABLE"))
+	f.set("SHOW FULL TABLES FROM `shop` WHERE Table_type = 'VIEW'", []string{"Tables_in_shop", "Table_type"})
+	f.set("SHOW CREATE TABLE `shop`.`orders`", []string{"Table", "Create Table"},
+		fakeRow("orders", "CREATE TABLE `orders` (\n  `id` int\n)"))
+	f.set(fmt.Sprint("SELECT COLUMN_NAME, EXTRA FROM information_schema.COLUMNS "+
+		"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", []driver.Value{"shop", "orders"}),
+		[]string{"COLUMN_NAME", "EXTRA"}, fakeRow("id", ""))
+	f.mu.Lock()
+	f.results["SELECT `id` FROM `shop`.`orders`*"] = fakeResult{columns: []string{"id"}, types: []string{"INT"}, rows: [][]driver.Value{fakeRow(int64(7))}}
+	f.mu.Unlock()
+}
+
+func TestMultipleDatabases(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	addShop(f)
+	got := dumpString(t, f, WithDatabases("db", "shop"))
+	want := []string{
+		"-- Current Database: `db`\n--\n\nCREATE DATABASE /*!32312 IF NOT EXISTS*/ `db` /*!40100 DEFAULT CHARACTER SET utf8mb4 */;\n\nUSE `db`;\n",
+		"INSERT INTO `users` VALUES",
+		"-- Current Database: `shop`\n--\n\nCREATE DATABASE /*!32312 IF NOT EXISTS*/ `shop`;\n\nUSE `shop`;\n",
+		"CREATE TABLE `orders`",
+		"INSERT INTO `orders` VALUES (7);\n",
+	}
+	rest := got
+	for _, s := range want {
+		i := strings.Index(rest, s)
+		if i < 0 {
+			t.Fatalf("dump doesn't have %q in order:\n%s", s, got)
+		}
+		rest = rest[i+len(s):]
+	}
+}
@@ -0,0 +1,31 @@
+package mysqldump
+
+import "regexp"
+
+// Matches the DEFINER clause of a CREATE statement, with the user and host
+// either quoted or bare, and the space after it.
+var definerClause = regexp.MustCompile("DEFINER\\s*=\\s*(?:CURRENT_USER(?:\\s*\\(\\s*\\))?|" +
+	"(?:`(?:[^`]|``)*`|'(?:[^']|'')*'|[^\\s@]+)@(?:`(?:[^`]|``)*`|'(?:[^']|'')*'|[^\\s]+))\\s*")
+
+// Leaves the DEFINER clause out of views, triggers, routines and events, so
+// they restore on servers without the original user and without the SUPER
+// privilege. The objects are then owned by the user restoring the dump.
+// SQL SECURITY is kept.
+func WithStripDefiners(enabled bool) Option {
+	return func(d *Dumper) {
+		d.stripDefiners = enabled
+	}
+}
+
+// Applies WithStripDefiners to a CREATE statement.
+func (d *Dumper) definer(create_sql string) string {
+	if !d.stripDefiners {
+		return create_sql
+	}
+	// Only the first match, the clause comes before the object's body
+	loc := definerClause.FindStringIndex(create_sql)
+	if loc == nil {
+		return create_sql
+	}
+	return create_sql[:loc[0]] + create_sql[loc[1]:]
+}
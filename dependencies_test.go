@@ -0,0 +1,56 @@
+package mysqldump
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDependencyOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		references [][]driver.Value // Table and the table it references
+		want       string
+	}{
+		{"none", nil, "orders,customers,items"},
+		{"foreign keys", [][]driver.Value{fakeRow("orders", "customers"), fakeRow("items", "orders")}, "customers,orders,items"},
+		{"self reference", [][]driver.Value{fakeRow("customers", "customers"), fakeRow("orders", "customers")}, "customers,orders,items"},
+		{"cycle", [][]driver.Value{fakeRow("orders", "items"), fakeRow("items", "orders")}, "customers,orders,items"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeDB()
+			for _, name := range []string{"orders", "customers", "items"} {
+				f.addTable(name, []string{"id"}, []string{"INT"}, fakeRow(int64(1)))
+			}
+			f.set(fmt.Sprint("SELECT DISTINCT TABLE_NAME, REFERENCED_TABLE_NAME FROM information_schema.KEY_COLUMN_USAGE "+
+				"WHERE TABLE_SCHEMA = ? AND REFERENCED_TABLE_SCHEMA = ? AND REFERENCED_TABLE_NAME IS NOT NULL", []driver.Value{"db", "db"}),
+				[]string{"TABLE_NAME", "REFERENCED_TABLE_NAME"}, test.references...)
+			d := newTestDumper(t, f, WithDependencyOrder(true))
+			tables, err := d.ListTables(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := make([]string, len(tables))
+			for i, table := range tables {
+				names[i] = table.Name
+			}
+			if got := strings.Join(names, ","); got != test.want {
+				t.Errorf("tables in order %s, want %s", got, test.want)
+			}
+
+			// The dump writes them in the same order
+			got := dumpString(t, f, WithDependencyOrder(true))
+			last := -1
+			for _, name := range names {
+				i := strings.Index(got, "CREATE TABLE `"+name+"`")
+				if i < last {
+					t.Errorf("dump doesn't have the tables in order %s:\n%s", test.want, got)
+				}
+				last = i
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package mysqldump
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// Dialect is how names and values are written in the statements a dump
+// generates, see WithDialect.
+type Dialect struct {
+	IdentifierQuote  string    // Quotes table and column names, doubled within them
+	StringQuote      string    // Quotes string values, doubled within them unless BackslashEscapes
+	BackslashEscapes bool      // Escapes strings with backslashes, like mysql_real_escape_string
+	NullLiteral      string    // Written for NULL values
+	BoolLiterals     [2]string // Written for BIT values of a single 0 or 1 byte, as false and true. Empty writes them as binary.
+	HexPrefix        string    // Written before the hex digits of binary values
+	HexSuffix        string    // Written after the hex digits of binary values
+}
+
+// The default dialect, as mysqldump writes it.
+var DialectMySQL = Dialect{
+	IdentifierQuote:  "`",
+	StringQuote:      "'",
+	BackslashEscapes: true,
+	NullLiteral:      "NULL",
+	HexPrefix:        "0x",
+}
+
+// Dialect for loading the rows of a dump into SQLite.
+var DialectSQLite = Dialect{
+	IdentifierQuote: `"`,
+	StringQuote:     "'",
+	NullLiteral:     "NULL",
+	BoolLiterals:    [2]string{"0", "1"},
+	HexPrefix:       "X'",
+	HexSuffix:       "'",
+}
+
+/*
+Writes the names and values of the statements the dump generates in dialect,
+to load them into other databases. Defaults to DialectMySQL. The statements
+themselves are still MYSQL's, and CREATE statements are written as the
+server returns them, so other databases can usually only load the rows. Use
+WithNoCreateInfo, WithLockTables(false) and a template without the header's
+SET statements for them. For example:
+
+	mysqldump.WithDialect(mysqldump.DialectSQLite)
+*/
+func WithDialect(dialect Dialect) Option {
+	return func(d *Dumper) {
+		d.dialect = dialect
+	}
+}
+
+func (dialect Dialect) valid() bool {
+	return dialect.IdentifierQuote != "" && dialect.StringQuote != "" && dialect.NullLiteral != ""
+}
+
+// Quotes a name with the dialect's identifier quote.
+func (dialect Dialect) quote(name string) string {
+	q := dialect.IdentifierQuote
+	return q + strings.Replace(name, q, q+q, -1) + q
+}
+
+func (dialect Dialect) appendHex(b, value []byte) []byte {
+	return append(hex.AppendEncode(append(b, dialect.HexPrefix...), value), dialect.HexSuffix...)
+}
+
+// Appends a BIT value as a bool literal, when the dialect has them and the
+// value is 0 or 1.
+func (dialect Dialect) appendBool(b, value []byte) ([]byte, bool) {
+	if len(value) != 1 || value[0] > 1 || dialect.BoolLiterals[value[0]] == "" {
+		return b, false
+	}
+	return append(b, dialect.BoolLiterals[value[0]]...), true
+}
@@ -1,117 +1,822 @@
 package mysqldump
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 )
 
 type table struct {
-	Name   string
-	SQL    string
-	Values string
+	Name     string
+	SQL      string
+	Triggers []string
+
+	d          *Dumper
+	ctx        context.Context
+	schema     string
+	primaryKey []string
+	listed     bool      // Values don't match the visible columns, so INSERTs list them
+	rows       *sql.Rows // Rows of the first range with any, see openValues
+	db         Querier
+	ranges     []keyRange
+	rangeIndex int // Range of rows
+	columns    []string
+	bound      string // Column bounding the rows, see WithSince
+	kinds      []valueKind
+	typeNames  string // Columns and their types, see WithColumnTypeComments
+	checksum   *rowChecksum
+	hasValues  bool
+	nonUnique  bool // Has non-unique indexes, see WithDisableKeys
+	quoteOnly  bool // Only escape quotes, see dump.NoBackslashEscapes
+	unlocked   bool // Dumped without LOCK TABLES, see WithSkipLockTablesOnError
+	progress   ProgressEvent
+	streaming  sync.WaitGroup // Stream's goroutine, see write
+	streamed   bool           // Stream was called, see write
+	err        error
+}
+
+// A stored procedure, function or event.
+type routine struct {
+	Type string // PROCEDURE, FUNCTION or EVENT
+	Name string
+	SQL  string
+}
+
+// Name of the routine quoted for use in SQL statements.
+func (r *routine) NameEsc() string {
+	return quoteIdentifier(r.Name)
 }
 
 type dump struct {
 	DumpVersion   string
 	ServerVersion string
-	Tables        []*table
+	Charset       string
+	DisableChecks bool
 	CompleteTime  string
+	Comments      []string // Lines from WithHeaderComment
+	Checksums     []string // Lines for each table, see WithChecksums. Only set for "footer".
+
+	// Binary log position of the dump, see WithMasterData
+	MasterLogFile string
+	MasterLogPos  string
+	ChangeMaster  bool
+
+	// The server has NO_BACKSLASH_ESCAPES in its sql_mode, so the dump
+	// escapes quotes by doubling them and restores with the same mode
+	NoBackslashEscapes bool
+}
+
+// Name of the table quoted for use in SQL statements.
+func (t *table) NameEsc() string {
+	// Views and databases have no dumper and keep their names
+	if t.d != nil {
+		return t.d.quoteName(t.d.renameTable(t.Name))
+	}
+	return quoteIdentifier(t.Name)
+}
+
+// Reports whether the table's DROP and CREATE statements are dumped.
+func (t *table) CreateInfo() bool {
+	return !t.d.noCreateInfo && !t.d.rowsOnly()
+}
+
+// Reports whether DROP TABLE is written before the table's CREATE statement.
+func (t *table) DropTable() bool {
+	return t.d.dropTable && !t.d.truncate
+}
+
+// Reports whether TRUNCATE TABLE is written before the table's rows.
+func (t *table) Truncate() bool {
+	return t.d.truncate && !t.d.noData
+}
+
+// Reports whether the table's rows are wrapped in LOCK TABLES.
+func (t *table) LockTables() bool {
+	return t.d.lockTables && !t.unlocked
+}
+
+// Reports whether index updates are turned off while the rows are inserted.
+func (t *table) DisableKeys() bool {
+	return t.d.disableKeys && t.nonUnique
+}
+
+// Names and types of the dumped columns, see WithColumnTypeComments.
+func (t *table) ColumnTypes() string {
+	return t.typeNames
+}
+
+// Reports whether the table has any rows to dump.
+func (t *table) HasValues() bool {
+	return t.hasValues
 }
 
 const version = "0.1.0"
 
-const tmpl = `-- Go SQL Dump {{ .DumpVersion }}
+// Number of rows read between checks for cancellation of the dump.
+const cancelCheckInterval = 100
+
+// Bytes of rows collected before they are written out.
+const streamChunkSize = 32 << 10
+
+// The dump is rendered in parts so table data can be streamed between them:
+// "header" once, then for each database "database" when dumping several,
+// "table" for each table, or "skipped" if it failed, "view" for each view, "routines" with all stored
+// procedures and functions and "events" with all scheduled events, and
+// finally "footer".
+const tmpl = `{{ define "header" }}{{ if comments }}-- Go SQL Dump {{ .DumpVersion }}
 --
 -- ------------------------------------------------------
 -- Server version	{{ .ServerVersion }}
+{{ end }}{{ range .Comments }}-- {{ . }}
+{{ end }}{{ if or comments .Comments }}
+{{ end }}SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT;
+SET @OLD_CHARACTER_SET_RESULTS=@@CHARACTER_SET_RESULTS;
+SET @OLD_COLLATION_CONNECTION=@@COLLATION_CONNECTION;
+SET NAMES {{ .Charset }};
+SET @OLD_TIME_ZONE=@@TIME_ZONE;
+SET TIME_ZONE='+00:00';
+SET @OLD_SQL_MODE=@@SQL_MODE;
+SET SQL_MODE='NO_AUTO_VALUE_ON_ZERO{{ if .NoBackslashEscapes }},NO_BACKSLASH_ESCAPES{{ end }}';
+{{ if .DisableChecks }}SET FOREIGN_KEY_CHECKS=0;
+SET UNIQUE_CHECKS=0;
+{{ end }}{{ if and .MasterLogFile (or .ChangeMaster comments) }}{{ if comments }}
+--
+-- Position to start replication or point-in-time recovery from
+--
+{{ end }}
+{{ if not .ChangeMaster }}-- {{ end }}CHANGE MASTER TO MASTER_LOG_FILE='{{ .MasterLogFile }}', MASTER_LOG_POS={{ .MasterLogPos }};
+{{ end }}
 
+{{ end }}{{ define "database" }}{{ if comments }}
+--
+-- Current Database: {{ .NameEsc }}
+--
+{{ end }}
+{{ .SQL }};
 
-{{range .Tables}}
+USE {{ .NameEsc }};
+{{ end }}{{ define "table" }}{{ if .CreateInfo }}{{ if comments }}
 --
 -- Table structure for table {{ .Name }}
 --
-
-DROP TABLE IF EXISTS {{ .Name }};
-{{ .SQL }};
-{{ if .Values }}
+{{ end }}
+{{ if .DropTable }}DROP TABLE IF EXISTS {{ .NameEsc }};
+{{ end }}{{ .SQL }};
+{{ end }}{{ if .Truncate }}
+TRUNCATE TABLE {{ .NameEsc }};
+{{ end }}{{ if .HasValues }}{{ if comments }}
 --
 -- Dumping data for table {{ .Name }}
 --
-
-LOCK TABLES {{ .Name }} WRITE;
-INSERT INTO {{ .Name }} VALUES {{ .Values }};
-UNLOCK TABLES;
-{{end}}{{ end }}
+{{ end }}
+{{ if and .ColumnTypes comments }}-- columns: {{ .ColumnTypes }}
+{{ end }}{{ if .LockTables }}LOCK TABLES {{ .NameEsc }} WRITE;
+{{ end }}{{ if .DisableKeys }}ALTER TABLE {{ .NameEsc }} DISABLE KEYS;
+{{ end }}{{ range .Stream }}{{ . }}{{ end }}
+{{ if .DisableKeys }}ALTER TABLE {{ .NameEsc }} ENABLE KEYS;
+{{ end }}{{ if .LockTables }}UNLOCK TABLES;
+{{ end }}{{ end }}{{ if .Triggers }}{{ if comments }}
+--
+-- Triggers for table {{ .Name }}
+--
+{{ end }}
+DELIMITER ;;
+{{ range .Triggers }}{{ . }};;
+{{ end }}DELIMITER ;
+{{ end }}{{ end }}{{ define "skipped" }}{{ if comments }}
+--
+-- Skipped table {{ .Name }}: {{ .Error }}
+--
+{{ end }}{{ end }}{{ define "view" }}{{ if comments }}
+--
+-- View structure for view {{ .Name }}
+--
+{{ end }}
+DROP VIEW IF EXISTS {{ .NameEsc }};
+{{ .SQL }};
+{{ end }}{{ define "routines" }}{{ if comments }}
+--
+-- Dumping routines
+--
+{{ end }}
+DELIMITER ;;
+{{ range . }}DROP {{ .Type }} IF EXISTS {{ .NameEsc }};;
+{{ .SQL }};;
+{{ end }}DELIMITER ;
+{{ end }}{{ define "events" }}{{ if comments }}
+--
+-- Dumping events
+--
+{{ end }}
+DELIMITER ;;
+{{ range . }}DROP EVENT IF EXISTS {{ .NameEsc }};;
+{{ .SQL }};;
+{{ end }}DELIMITER ;
+{{ end }}{{ define "footer" }}
+{{ if .DisableChecks }}SET FOREIGN_KEY_CHECKS=1;
+SET UNIQUE_CHECKS=1;
+{{ end }}SET SQL_MODE=@OLD_SQL_MODE;
+SET TIME_ZONE=@OLD_TIME_ZONE;
+SET CHARACTER_SET_CLIENT=@OLD_CHARACTER_SET_CLIENT;
+SET CHARACTER_SET_RESULTS=@OLD_CHARACTER_SET_RESULTS;
+SET COLLATION_CONNECTION=@OLD_COLLATION_CONNECTION;
+{{ if .Checksums }}
+{{ range .Checksums }}-- Checksum {{ . }}
+{{ end }}{{ end }}{{ if comments }}
 -- Dump completed on {{ .CompleteTime }}
-`
+{{ end }}{{ end }}`
+
+var dumpTemplate = template.Must(template.New("mysqldump").Funcs(template.FuncMap{
+	"comments": func() bool { return true },
+}).Parse(tmpl))
+
+// The default template without comments, see WithComments.
+var bareTemplate = template.Must(dumpTemplate.Clone()).Funcs(template.FuncMap{
+	"comments": func() bool { return false },
+})
+
+// Templates that a custom template must define, see WithTemplate.
+var requiredTemplates = []string{"header", "table", "footer"}
+
+// Renders the named part of the dump with the custom template if it defines
+// it, otherwise with the default.
+func (d *Dumper) execute(w io.Writer, name string, data interface{}) error {
+	// Other output formats only have the rows, see table.writeRows
+	if d.rowsOnly() {
+		return nil
+	}
+	if d.template != nil && d.template.Lookup(name) != nil {
+		return d.template.ExecuteTemplate(w, name, data)
+	}
+	if d.noComments {
+		return bareTemplate.ExecuteTemplate(w, name, data)
+	}
+	return dumpTemplate.ExecuteTemplate(w, name, data)
+}
 
 // Creates a MYSQL Dump based on the options supplied through the dumper.
+// The dump is written to a '.partial' file which is renamed once complete,
+// so the dump directory never holds an incomplete dump under its final name.
+// If the dump fails the partially written file is removed.
 func (d *Dumper) Dump() error {
-	name := time.Now().Format(d.format)
-	p := path.Join(d.dir, name+".sql")
+	return d.DumpContext(context.Background())
+}
 
-	// Check dump directory
-	if e, _ := exists(p); e {
-		return errors.New("Dump '" + name + "' already exists.")
+// Same as Dump but stops and removes the partial dump when ctx is cancelled.
+func (d *Dumper) DumpContext(ctx context.Context) error {
+	_, err := d.dumpStats(ctx)
+	return err
+}
+
+// Same as Dump but also returns what was dumped.
+func (d *Dumper) DumpWithStats() (Stats, error) {
+	return d.dumpStats(context.Background())
+}
+
+func (d *Dumper) dumpStats(ctx context.Context) (Stats, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+	run := &dumpRun{}
+	start := time.Now()
+	err := d.dumpFile(ctx, d.now().Format(d.format), run)
+	run.stats.Duration = time.Since(start)
+	if err == nil {
+		err = run.failures()
 	}
+	return run.stats, err
+}
 
-	// Create .sql file
-	f, err := os.Create(p)
+// Applies WithTimeout to ctx.
+func (d *Dumper) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d.timeout)
+}
+
+func (d *Dumper) dumpFile(ctx context.Context, name string, run *dumpRun) (err error) {
+	if d.filePerTable {
+		return d.dumpFiles(ctx, name, run)
+	}
+	if d.maxFileSize > 0 {
+		return d.dumpParts(ctx, name, run)
+	}
+
+	// Create partial dump file, exclusively so concurrent dumps can't share it
+	var p, partial string
+	var f *os.File
+	err = d.claimName(name, func(name string) (err error) {
+		p = path.Join(d.dir, name+d.extension())
+		if e, _ := exists(p); e {
+			return fs.ErrExist
+		}
+		partial = p + ".partial"
+		f, err = os.OpenFile(partial, os.O_WRONLY|os.O_CREATE|os.O_EXCL, d.fileMode)
+		return err
+	})
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	defer func() {
+		// Remove partially written dump, also when a callback panics
+		r := recover()
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil || r != nil {
+			os.Remove(partial)
+		} else if err = renameNew(partial, p); err != nil {
+			os.Remove(partial)
+		}
+		if r != nil {
+			panic(r)
+		}
+	}()
 
-	data := dump{
-		DumpVersion: version,
-		Tables:      make([]*table, 0),
+	return d.dumpEncoded(ctx, f, run)
+}
+
+// Calls create with the dump's name until it doesn't fail with fs.ErrExist.
+// With WithUniqueSuffix each retry adds '-1', '-2' and so on to the name,
+// otherwise the dump fails as it already exists.
+func (d *Dumper) claimName(name string, create func(name string) error) error {
+	for n := 1; ; n++ {
+		candidate := name
+		if n > 1 {
+			candidate = name + "-" + strconv.Itoa(n-1)
+		}
+		err := create(candidate)
+		if !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		if !d.uniqueSuffix {
+			return errors.New("Dump '" + candidate + "' already exists.")
+		}
 	}
+}
+
+// Writes a MYSQL Dump to w instead of a file in the dump directory.
+func (d *Dumper) DumpTo(w io.Writer) error {
+	return d.dumpComplete(context.Background(), w, &dumpRun{})
+}
+
+// Writes a MYSQL Dump of only the structure and data of the named table of
+// the connection's database to w, without listing the database's tables.
+// The include and exclude filters don't apply.
+func (d *Dumper) DumpTable(ctx context.Context, w io.Writer, name string) error {
+	return d.dumpComplete(ctx, w, &dumpRun{table: name})
+}
+
+// Returns the dump as a stream, for callers that need a reader such as HTTP
+// responses or uploads. The dump runs while the stream is read and any error
+// is returned by Read. Closing the reader or cancelling ctx stops the dump.
+func (d *Dumper) Reader(ctx context.Context) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer cancel()
+		pw.CloseWithError(d.dumpComplete(ctx, pw, &dumpRun{}))
+	}()
+	return &dumpReader{pr, cancel, done}
+}
 
-	// Get server version
-	if data.ServerVersion, err = getServerVersion(d.db); err != nil {
+type dumpReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+	done   chan struct{} // Closed once the dump has returned
+}
+
+// Stops the dump, which fails its next write to the closed pipe if it is
+// not waiting on the database, and waits for it to return.
+func (r *dumpReader) Close() error {
+	r.cancel()
+	err := r.PipeReader.Close()
+	<-r.done
+	return err
+}
+
+// Same as dumpEncoded but also fails with any tables skipped by WithContinueOnError.
+func (d *Dumper) dumpComplete(ctx context.Context, w io.Writer, run *dumpRun) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+	if err := d.dumpEncoded(ctx, w, run); err != nil {
 		return err
 	}
+	return run.failures()
+}
 
-	// Get tables
-	tables, err := getTables(d.db)
+// Writes the dump to w, compressed and encrypted when enabled.
+func (d *Dumper) dumpEncoded(ctx context.Context, w io.Writer, run *dumpRun) (err error) {
+	cw, err := d.encodeWriter(run.count(w))
+	if err != nil {
+		return err
+	}
+	// Flush encoded data before the underlying writer is closed
+	defer func() {
+		if cerr := cw.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	return d.dumpTo(ctx, cw, run)
+}
+
+// Writes the uncompressed dump to w. Tables go to their own files instead when run.files is set.
+func (d *Dumper) dumpTo(ctx context.Context, w io.Writer, run *dumpRun) (err error) {
+	// Stop any in flight row streams once the dump returns
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Read everything from one connection so session settings apply to all queries
+	conn, err := d.openConn(ctx)
 	if err != nil {
 		return err
 	}
+	defer conn.Close()
 
-	// Get sql for each table
-	for _, name := range tables {
-		if t, err := createTable(d.db, name); err == nil {
-			data.Tables = append(data.Tables, t)
+	// Optionally block writes with a global read lock, held until the dump
+	// is read or, with a snapshot, only until the snapshot has started
+	locked := false
+	if d.flushLock || d.masterData {
+		if locked, err = d.flushTables(ctx, conn); err != nil {
+			return fmt.Errorf("locking tables: %w", err)
+		}
+		defer func() {
+			// The connection goes back to the pool, so the lock must be released
+			if locked {
+				if uerr := unlockTables(conn); err == nil {
+					err = uerr
+				}
+			}
+		}()
+	}
+
+	// Optionally inside a consistent snapshot
+	if d.consistentSnapshot {
+		if err = beginSnapshot(ctx, conn); err != nil {
+			return err
+		}
+		defer func() {
+			err = endSnapshot(conn, err)
+		}()
+	}
+
+	data := dump{
+		DumpVersion:   version,
+		Charset:       d.charset,
+		DisableChecks: d.disableChecks,
+		Comments:      d.headerComments,
+	}
+
+	// Read the binary log position while writes are blocked
+	if d.masterData {
+		if data.MasterLogFile, data.MasterLogPos, err = getMasterStatus(ctx, conn); err != nil {
+			return fmt.Errorf("reading master status: %w", err)
+		}
+		data.ChangeMaster = d.changeMaster
+	}
+	if locked && d.consistentSnapshot {
+		locked = false
+		if err = unlockTables(conn); err != nil {
+			return err
+		}
+	}
+	var q Querier = conn
+
+	// Get server version, written as overridden but used as is
+	if run.serverVersion, err = getServerVersion(ctx, q); err != nil {
+		return fmt.Errorf("getting server version: %w", err)
+	}
+	data.ServerVersion = run.serverVersion
+	if d.serverVersionSet {
+		data.ServerVersion = d.serverVersion
+	}
+	if data.NoBackslashEscapes, err = noBackslashEscapes(ctx, q); err != nil {
+		return fmt.Errorf("reading sql_mode: %w", err)
+	}
+	run.data = data
+
+	// Get databases, a single table is read from the connection's database
+	var schemas []string
+	if run.table != "" {
+		var schema string
+		schema, err = getCurrentDatabase(ctx, q)
+		schemas = []string{schema}
+	} else {
+		schemas, err = d.getDatabases(ctx, q)
+	}
+	if err != nil {
+		return fmt.Errorf("listing databases: %w", err)
+	}
+
+	start := time.Now()
+	d.logger.Info("starting dump", "databases", len(schemas))
+	defer func() {
+		if err != nil {
+			d.logger.Error("dump failed", "error", err)
+		} else {
+			d.logger.Info("dump complete", "duration", time.Since(start))
+		}
+	}()
+
+	// Write header
+	if err = d.execute(w, "header", data); err != nil {
+		return err
+	}
+
+	// Write each database, or only the table
+	for _, schema := range schemas {
+		if run.table != "" {
+			err = d.writeTables(ctx, q, w, schema, []string{run.table}, run)
 		} else {
+			err = d.dumpDatabase(ctx, q, w, schema, run)
+		}
+		if err != nil {
 			return err
 		}
 	}
 
 	// Set complete time
-	data.CompleteTime = time.Now().String()
+	data.CompleteTime = d.completeTime()
+	data.Checksums = run.checksums()
+
+	// Write footer
+	return d.execute(w, "footer", data)
+}
+
+// Writes the tables, views, routines and events of one database.
+func (d *Dumper) dumpDatabase(ctx context.Context, q Querier, w io.Writer, schema string, run *dumpRun) error {
+	// Get tables
+	tables, err := d.dumpedTables(ctx, q, schema)
+	if err != nil {
+		return err
+	}
+	if run.files != nil {
+		tables = run.files.remaining(schema, tables)
+	}
+
+	// Get views
+	var views []string
+	if !d.noCreateInfo && !d.rowsOnly() {
+		if views, err = getViews(ctx, q, schema); err != nil {
+			return fmt.Errorf("listing views: %w", err)
+		}
+		views = d.filterTables(views)
+	}
 
-	// Write dump to file
-	t, err := template.New("mysqldump").Parse(tmpl)
+	d.logger.Info("dumping database", "database", schema, "tables", len(tables), "views", len(views))
+
+	// Write database creation when asked to or when dumping several databases
+	var db *table
+	if (d.createDatabase || d.multipleDatabases()) && !d.rowsOnly() {
+		if db, err = createDatabase(ctx, q, schema); err != nil {
+			return fmt.Errorf("dumping database %q: %w", schema, err)
+		}
+		if err = d.execute(w, "database", db); err != nil {
+			return err
+		}
+	}
+	if run.files != nil {
+		run.files.database = db
+	}
+	if d.skipLockTables(ctx, q, schema) {
+		run.unlocked = append(run.unlocked, schema)
+	}
+
+	// Write structure and data for each table
+	if d.concurrency > 1 {
+		err = d.writeTablesConcurrently(ctx, w, schema, tables, run)
+	} else {
+		err = d.writeTables(ctx, q, w, schema, tables, run)
+	}
 	if err != nil {
 		return err
 	}
-	if err = t.Execute(f, data); err != nil {
+
+	// Write views after all tables as they depend on them
+	for _, name := range views {
+		v, err := createView(ctx, q, schema, name)
+		if err != nil {
+			return fmt.Errorf("dumping view %q: %w", name, err)
+		}
+		v.SQL = d.definer(v.SQL)
+		if err = d.execute(w, "view", v); err != nil {
+			return err
+		}
+	}
+
+	// Write routines
+	if d.routines && !d.rowsOnly() {
+		routines, err := getRoutines(ctx, q, schema)
+		if err != nil {
+			return fmt.Errorf("dumping routines: %w", err)
+		}
+		for _, r := range routines {
+			r.SQL = d.definer(r.SQL)
+		}
+		if len(routines) > 0 {
+			if err = d.execute(w, "routines", routines); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Write events
+	if d.events && !d.rowsOnly() {
+		events, err := getEvents(ctx, q, schema)
+		if err != nil {
+			return fmt.Errorf("dumping events: %w", err)
+		}
+		for _, e := range events {
+			e.SQL = d.definer(e.SQL)
+		}
+		if len(events) > 0 {
+			if err = d.execute(w, "events", events); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// A connection a dump reads through, closed once it is done.
+type dumpConn interface {
+	Querier
+	Close() error
+}
+
+// A connection given to RegisterQuerier, which is left open when closed.
+type borrowedConn struct {
+	Querier
+}
+
+func (borrowedConn) Close() error {
+	return nil
+}
+
+// Opens a dedicated connection with the dump's session settings. A Dumper
+// registered with a *sql.Conn or *sql.Tx reads through it instead.
+func (d *Dumper) openConn(ctx context.Context) (dumpConn, error) {
+	var conn dumpConn = borrowedConn{d.db}
+	if db, ok := d.db.(*sql.DB); ok {
+		c, err := db.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		conn = c
+	}
+	// Read TIMESTAMP columns in UTC to match the dump's TIME_ZONE
+	for _, query := range []string{"SET NAMES " + d.charset, "SET TIME_ZONE='+00:00'"} {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (d *Dumper) writeTables(ctx context.Context, q Querier, w io.Writer, schema string, tables []string, run *dumpRun) error {
+	for i, name := range tables {
+		var stats TableStats
+		var written int64
+		err := run.write(w, schema, name, func(w io.Writer) (err error) {
+			stats, err = d.writeTable(ctx, q, &countingWriter{w, &written}, run, schema, name, i, len(tables))
+			return err
+		})
+		if err != nil {
+			if err = d.skipTable(ctx, w, run, schema, name, written, err); err != nil {
+				return err
+			}
+			continue
+		}
+		run.addTable(stats)
+		if err = run.checkpoint(stats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes the structure and data of the index'th of count tables in schema.
+// Returns what was written.
+func (d *Dumper) writeTable(ctx context.Context, q Querier, w io.Writer, run *dumpRun, schema, name string, index, count int) (TableStats, error) {
+	progress := ProgressEvent{Database: schema, Table: name, TableIndex: index, TableCount: count}
+	d.reportProgress(progress)
+	start := time.Now()
+	d.logger.Debug("dumping table", "database", schema, "table", name)
+
+	var t *table
+	err := d.retry(ctx, schema, name, func() (err error) {
+		t, err = d.createTable(ctx, q, schema, name)
 		return err
+	})
+	if err == nil {
+		t.progress = progress
+		d.warnQuotedNames(t)
+		if d.portableDDL && isMariaDB(run.serverVersion) {
+			t.SQL = portableCreate(t.SQL)
+		}
+		t.quoteOnly = run.data.NoBackslashEscapes
+		t.unlocked = run.isUnlocked(schema)
+		if d.checksums {
+			t.checksum = newRowChecksum()
+		}
+		err = t.write(w)
+	}
+	if err != nil {
+		d.logger.Error("dumping table failed", "database", schema, "table", name, "error", err)
+		return TableStats{}, fmt.Errorf("dumping table %q: %w", name, err)
+	}
+
+	d.logger.Info("dumped table", "database", schema, "table", name, "rows", t.progress.Rows, "duration", time.Since(start))
+	stats := TableStats{Database: schema, Table: name, Rows: t.progress.Rows}
+	if t.checksum != nil {
+		stats.Checksum = t.checksum.String()
 	}
+	return stats, nil
+}
+
+// Time for the "Dump completed on" line, in UTC.
+func (d *Dumper) completeTime() string {
+	return d.now().UTC().Format(d.timeFormat)
+}
 
+// Starts a consistent snapshot on conn, like mysqldump's --single-transaction.
+func beginSnapshot(ctx context.Context, conn Querier) error {
+	for _, query := range []string{
+		"SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ",
+		"START TRANSACTION WITH CONSISTENT SNAPSHOT",
+	} {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func getTables(db *sql.DB) ([]string, error) {
+// Commits the snapshot, or rolls it back if the dump failed.
+// Returns the dump error if there was one.
+func endSnapshot(conn Querier, dumpErr error) error {
+	query := "COMMIT"
+	if dumpErr != nil {
+		query = "ROLLBACK"
+	}
+	_, err := conn.ExecContext(context.Background(), query)
+	if dumpErr != nil {
+		return dumpErr
+	}
+	return err
+}
+
+// Releases the global read lock taken by WithFlushLock.
+func unlockTables(conn Querier) error {
+	_, err := conn.ExecContext(context.Background(), "UNLOCK TABLES")
+	return err
+}
+
+// Lists the base tables of schema left by the include and exclude filters,
+// in the order they are dumped, see WithDependencyOrder.
+func (d *Dumper) dumpedTables(ctx context.Context, q Querier, schema string) ([]string, error) {
+	tables, err := getTables(ctx, q, schema)
+	if err != nil {
+		return nil, fmt.Errorf("listing tables: %w", err)
+	}
+	tables = d.filterTables(tables)
+	if d.dependencyOrder {
+		if tables, err = sortByDependencies(ctx, q, schema, tables); err != nil {
+			return nil, fmt.Errorf("ordering tables: %w", err)
+		}
+	}
+	return tables, nil
+}
+
+func getTables(ctx context.Context, db Querier, schema string) ([]string, error) {
+	return listTables(ctx, db, schema, "BASE TABLE")
+}
+
+func getViews(ctx context.Context, db Querier, schema string) ([]string, error) {
+	return listTables(ctx, db, schema, "VIEW")
+}
+
+func listTables(ctx context.Context, db Querier, schema, table_type string) ([]string, error) {
 	tables := make([]string, 0)
 
 	// Get table list
-	rows, err := db.Query("SHOW TABLES")
+	rows, err := db.QueryContext(ctx, "SHOW FULL TABLES FROM "+quoteIdentifier(schema)+" WHERE Table_type = '"+table_type+"'")
 	if err != nil {
 		return tables, err
 	}
@@ -119,8 +824,8 @@ func getTables(db *sql.DB) ([]string, error) {
 
 	// Read result
 	for rows.Next() {
-		var table string
-		if err := rows.Scan(&table); err != nil {
+		var table, t string
+		if err := rows.Scan(&table, &t); err != nil {
 			return tables, err
 		}
 		tables = append(tables, table)
@@ -128,34 +833,110 @@ func getTables(db *sql.DB) ([]string, error) {
 	return tables, rows.Err()
 }
 
-func getServerVersion(db *sql.DB) (string, error) {
+// Applies the include and exclude filters to a table list.
+// Exclusions win when a table matches both.
+func (d *Dumper) filterTables(tables []string) []string {
+	filtered := make([]string, 0, len(tables))
+	for _, name := range tables {
+		if len(d.includeTables) > 0 && !matchAny(d.includeTables, name) {
+			continue
+		}
+		if matchAny(d.excludeTables, name) {
+			continue
+		}
+		filtered = append(filtered, name)
+	}
+	return filtered
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether the server's sql_mode treats backslashes as ordinary characters.
+func noBackslashEscapes(ctx context.Context, db Querier) (bool, error) {
+	var sql_mode string
+	if err := db.QueryRowContext(ctx, "SELECT @@SESSION.sql_mode").Scan(&sql_mode); err != nil {
+		return false, err
+	}
+	for _, mode := range strings.Split(strings.ToUpper(sql_mode), ",") {
+		if mode == "NO_BACKSLASH_ESCAPES" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func getServerVersion(ctx context.Context, db Querier) (string, error) {
 	var server_version string
-	if err := db.QueryRow("SELECT version()").Scan(&server_version); err != nil {
+	if err := db.QueryRowContext(ctx, "SELECT version()").Scan(&server_version); err != nil {
 		return "", err
 	}
 	return server_version, nil
 }
 
-func createTable(db *sql.DB, name string) (*table, error) {
+func (d *Dumper) createTable(ctx context.Context, db Querier, schema, name string) (*table, error) {
 	var err error
-	t := &table{Name: name}
+	t := &table{Name: name, d: d, ctx: ctx, schema: schema}
 
-	if t.SQL, err = createTableSQL(db, name); err != nil {
-		return nil, err
+	if t.CreateInfo() {
+		if t.SQL, err = createTableSQL(ctx, db, schema, name); err != nil {
+			return nil, fmt.Errorf("reading structure: %w", err)
+		}
+		t.SQL = d.renameCreate(name, t.SQL)
+		t.SQL = d.rewriteAutoIncrement(t.SQL)
+		t.SQL = d.rewriteTableOptions(t.SQL)
+		if d.createIfNotExists || d.truncate {
+			t.SQL = createIfNotExists(t.SQL)
+		}
 	}
 
-	if t.Values, err = createTableValues(db, name); err != nil {
+	// Triggers are read first as the connection is busy once rows are streaming
+	if d.triggers && !d.rowsOnly() {
+		if t.Triggers, err = createTriggersSQL(ctx, db, schema, name); err != nil {
+			return nil, fmt.Errorf("reading triggers: %w", err)
+		}
+		for i, trigger := range t.Triggers {
+			t.Triggers[i] = d.definer(trigger)
+		}
+	}
+
+	if d.noData {
+		return t, nil
+	}
+	dumped, err := t.readRowsQuery(ctx, db)
+	if err != nil {
 		return nil, err
 	}
+	if !dumped {
+		return t, nil
+	}
+	if d.disableKeys && !d.rowsOnly() {
+		if t.nonUnique, err = hasNonUniqueIndex(ctx, db, schema, name); err != nil {
+			return nil, fmt.Errorf("reading indexes: %w", err)
+		}
+	}
+	// A table without columns, or with only generated ones, has no data to dump
+	if len(t.columns) == 0 {
+		return t, nil
+	}
+	if err = t.openValues(db); err != nil {
+		return nil, fmt.Errorf("reading data: %w", err)
+	}
 
 	return t, nil
 }
 
-func createTableSQL(db *sql.DB, name string) (string, error) {
+func createTableSQL(ctx context.Context, db Querier, schema, name string) (string, error) {
 	// Get table creation SQL
 	var table_return string
 	var table_sql string
-	err := db.QueryRow("SHOW CREATE TABLE "+name).Scan(&table_return, &table_sql)
+	err := db.QueryRowContext(ctx, "SHOW CREATE TABLE "+qualify(schema, name)).Scan(&table_return, &table_sql)
 	if err != nil {
 		return "", err
 	}
@@ -166,39 +947,722 @@ func createTableSQL(db *sql.DB, name string) (string, error) {
 	return table_sql, nil
 }
 
-func createTableValues(db *sql.DB, name string) (string, error) {
-	// Get Data
-	rows, err := db.Query("SELECT * FROM " + name)
+// Rewrites a CREATE TABLE statement to CREATE TABLE IF NOT EXISTS. The table
+// name follows unchanged, so any quoting is kept.
+func createIfNotExists(table_sql string) string {
+	const prefix = "CREATE TABLE "
+	if !strings.HasPrefix(table_sql, prefix) || strings.HasPrefix(table_sql, prefix+"IF NOT EXISTS ") {
+		return table_sql
+	}
+	return prefix + "IF NOT EXISTS " + table_sql[len(prefix):]
+}
+
+func createTriggersSQL(ctx context.Context, db Querier, schema, name string) ([]string, error) {
+	// Get trigger names
+	rows, err := db.QueryContext(ctx, "SELECT TRIGGER_NAME FROM information_schema.TRIGGERS "+
+		"WHERE EVENT_OBJECT_SCHEMA = ? AND EVENT_OBJECT_TABLE = ? ORDER BY ACTION_ORDER", schema, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	triggers := make([]string, 0)
+	for rows.Next() {
+		var trigger string
+		if err := rows.Scan(&trigger); err != nil {
+			return nil, err
+		}
+		triggers = append(triggers, trigger)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Get trigger creation SQL
+	for i, trigger := range triggers {
+		if triggers[i], err = showCreate(ctx, db, "SHOW CREATE TRIGGER "+qualify(schema, trigger), "SQL Original Statement"); err != nil {
+			return nil, err
+		}
+	}
+	return triggers, nil
+}
+
+// Lists the primary key columns of a table in key order. Empty if it has none.
+func getPrimaryKey(ctx context.Context, db Querier, schema, name string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE "+
+		"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION", schema, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make([]string, 0)
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// Reports whether a table has an index that isn't unique, which is all
+// DISABLE KEYS defers.
+func hasNonUniqueIndex(ctx context.Context, db Querier, schema, name string) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.STATISTICS "+
+		"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND NON_UNIQUE = 1", schema, name).Scan(&count)
+	return count > 0, err
+}
+
+// Leaves out the columns excluded by WithExcludeColumns, which INSERTs must then list.
+func (d *Dumper) keepColumns(name string, columns []string, listed bool) ([]string, bool) {
+	excluded := d.excludeColumns[name]
+	if len(excluded) == 0 {
+		return columns, listed
+	}
+	skip := make(map[string]bool, len(excluded))
+	for _, column := range excluded {
+		skip[column] = true
+	}
+	kept := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if !skip[column] {
+			kept = append(kept, column)
+		}
+	}
+	return kept, listed || len(kept) < len(columns)
+}
+
+// Lists the columns of a table to dump, and reports whether INSERTs must
+// list them. Generated columns are left out as the server rejects values for
+// them, and INVISIBLE columns are kept although INSERTs without a column list
+// leave them out.
+func getColumns(ctx context.Context, db Querier, schema, name string) ([]string, bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT COLUMN_NAME, EXTRA FROM information_schema.COLUMNS "+
+		"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", schema, name)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	columns := make([]string, 0)
+	listed := false
+	for rows.Next() {
+		var column, extra string
+		if err := rows.Scan(&column, &extra); err != nil {
+			return nil, false, err
+		}
+		if isGenerated(extra) {
+			listed = true
+			continue
+		}
+		if strings.Contains(strings.ToUpper(extra), "INVISIBLE") {
+			listed = true
+		}
+		columns = append(columns, column)
+	}
+	return columns, listed, rows.Err()
+}
+
+// Reports whether a column's EXTRA marks it as generated. Columns with
+// DEFAULT_GENERATED only have an expression as default and are dumped.
+func isGenerated(extra string) bool {
+	extra = strings.ToUpper(extra)
+	return strings.Contains(extra, "VIRTUAL GENERATED") ||
+		strings.Contains(extra, "STORED GENERATED") ||
+		strings.Contains(extra, "PERSISTENT GENERATED")
+}
+
+// Runs a SHOW CREATE statement and returns the named column of its result.
+// Used where the number of columns returned differs between server versions.
+func showCreate(ctx context.Context, db Querier, query, column string) (string, error) {
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return "", err
 	}
 	defer rows.Close()
 
-	// Get columns
 	columns, err := rows.Columns()
 	if err != nil {
 		return "", err
 	}
-	if len(columns) == 0 {
-		return "", errors.New("No columns in table " + name + ".")
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		return "", sql.ErrNoRows
+	}
+	data := make([]sql.RawBytes, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i, _ := range data {
+		ptrs[i] = &data[i]
 	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return "", err
+	}
+	for i, name := range columns {
+		if name == column {
+			return string(data[i]), nil
+		}
+	}
+	return "", errors.New("Column '" + column + "' missing from " + query)
+}
+
+// Lists the stored procedures and functions of schema with their creation SQL.
+func getRoutines(ctx context.Context, db Querier, schema string) ([]*routine, error) {
+	rows, err := db.QueryContext(ctx, "SELECT ROUTINE_TYPE, ROUTINE_NAME FROM information_schema.ROUTINES "+
+		"WHERE ROUTINE_SCHEMA = ? ORDER BY ROUTINE_TYPE, ROUTINE_NAME", schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	routines := make([]*routine, 0)
+	for rows.Next() {
+		r := &routine{}
+		if err := rows.Scan(&r.Type, &r.Name); err != nil {
+			return nil, err
+		}
+		routines = append(routines, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
 
-	// Read data
-	data_text := make([]string, 0)
+	// Get routine creation SQL
+	for _, r := range routines {
+		column := "Create Procedure"
+		if r.Type == "FUNCTION" {
+			column = "Create Function"
+		}
+		if r.SQL, err = showCreate(ctx, db, "SHOW CREATE "+r.Type+" "+qualify(schema, r.Name), column); err != nil {
+			return nil, err
+		}
+	}
+	return routines, nil
+}
+
+// Lists the scheduled events of schema with their creation SQL.
+func getEvents(ctx context.Context, db Querier, schema string) ([]*routine, error) {
+	rows, err := db.QueryContext(ctx, "SELECT EVENT_NAME FROM information_schema.EVENTS "+
+		"WHERE EVENT_SCHEMA = ? ORDER BY EVENT_NAME", schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]*routine, 0)
 	for rows.Next() {
-		// Init temp data storage
-		data := make([]string, len(columns))
-		ptrs := make([]interface{}, len(columns))
-		for i, _ := range data {
-			ptrs[i] = &data[i]
+		e := &routine{Type: "EVENT"}
+		if err := rows.Scan(&e.Name); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Get event creation SQL
+	for _, e := range events {
+		if e.SQL, err = showCreate(ctx, db, "SHOW CREATE EVENT "+qualify(schema, e.Name), "Create Event"); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+func createView(ctx context.Context, db Querier, schema, name string) (*table, error) {
+	// Get view creation SQL
+	var view_return, view_sql, charset, collation string
+	err := db.QueryRowContext(ctx, "SHOW CREATE VIEW "+qualify(schema, name)).Scan(&view_return, &view_sql, &charset, &collation)
+	if err != nil {
+		return nil, err
+	}
+	if view_return != name {
+		return nil, errors.New("Returned view is not the same as requested view")
+	}
+
+	return &table{Name: name, SQL: view_sql}, nil
+}
+
+// Starts reading the table's data. The rows are left open, positioned on the
+// first row, so they can be streamed while the table is written.
+// Reads what selectQuery needs to read the rows as dumped: the primary key,
+// columns and key ranges. Reports whether the table's rows are dumped.
+// Verify reads the rows the same way, so the checksums match.
+func (t *table) readRowsQuery(ctx context.Context, db Querier) (bool, error) {
+	var err error
+	d := t.d
+	if d.orderByPrimaryKey || d.upsert || d.keyRanges[t.Name] > 0 {
+		if t.primaryKey, err = getPrimaryKey(ctx, db, t.schema, t.Name); err != nil {
+			return false, fmt.Errorf("reading primary key: %w", err)
+		}
+	}
+	if t.columns, t.listed, err = getColumns(ctx, db, t.schema, t.Name); err != nil {
+		return false, fmt.Errorf("reading columns: %w", err)
+	}
+	// The incremental column bounds the rows even when it is excluded
+	var dumped bool
+	if t.bound, dumped = d.incrementalColumn(t.Name, t.columns); !dumped {
+		return false, nil
+	}
+	t.columns, t.listed = d.keepColumns(t.Name, t.columns, t.listed)
+	if parts := d.keyRanges[t.Name]; parts > 0 && len(t.columns) > 0 {
+		if t.ranges, err = t.keyRanges(ctx, db, parts); err != nil {
+			return false, fmt.Errorf("reading primary key ranges: %w", err)
+		}
+	}
+	return true, nil
+}
+
+func (t *table) openValues(db Querier) error {
+	// Ranges without rows are skipped, so only an empty table has no values
+	t.db = db
+	for {
+		rows, err := t.queryValues(db, t.rangeIndex, t.d.rowLimit)
+		if err != nil {
+			return err
+		}
+		if rows != nil {
+			t.rows = rows
+			t.hasValues = true
+			return nil
+		}
+		if t.rangeIndex+1 >= len(t.ranges) {
+			return nil
+		}
+		t.rangeIndex++
+	}
+}
+
+// Runs the query reading the table's rows, or those of a range, and returns
+// them positioned on the first row, or nil if there are none.
+func (t *table) queryValues(db Querier, index, limit int) (*sql.Rows, error) {
+	// Get Data
+	query, args := t.selectQuery(index, limit)
+	rows, err := db.QueryContext(t.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Columns are the same for every range, read before the rows stream
+	if t.kinds == nil {
+		if err := t.readColumnTypes(rows); err != nil {
+			rows.Close()
+			return nil, err
+		}
+	}
+
+	// Fail here on errors reading the first row, before anything is written
+	if !rows.Next() {
+		defer rows.Close()
+		return nil, rows.Err()
+	}
+	return rows, nil
+}
+
+// Sets the table's columns and their value kinds from the rows.
+func (t *table) readColumnTypes(rows *sql.Rows) error {
+	// Get columns
+	var err error
+	if t.columns, err = rows.Columns(); err != nil {
+		return err
+	}
+
+	// Get column types
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	t.kinds = make([]valueKind, len(types))
+	for i, columnType := range types {
+		t.kinds[i] = columnKind(columnType.DatabaseTypeName())
+	}
+	if t.d.columnTypeComments {
+		t.typeNames = typeNames(t.columns, types)
+	}
+	return nil
+}
+
+// Query used to read the table's data. The columns from getColumns are
+// always listed, so values come in the order INSERTs expect whatever the
+// live schema's column order. Only the rows of the range at index are read
+// when the table is split into ranges, at most limit of them if it is set.
+func (t *table) selectQuery(index, limit int) (string, []interface{}) {
+	query := "SELECT " + quoteList(t.columns) + " FROM " + qualify(t.schema, t.Name)
+	var conditions []string
+	var args []interface{}
+	if t.bound != "" {
+		conditions = append(conditions, quoteIdentifier(t.bound)+" >= ?")
+		args = append(args, t.d.sinceValue())
+	}
+	if len(t.ranges) > 0 {
+		conditions = append(conditions, t.ranges[index].condition(t.primaryKey[0]))
+	}
+	if where, ok := t.d.where[t.Name]; ok {
+		if len(conditions) > 0 {
+			where = "(" + where + ")"
+		}
+		conditions = append([]string{where}, conditions...)
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if t.d.orderByPrimaryKey && len(t.primaryKey) > 0 {
+		query += " ORDER BY " + quoteList(t.primaryKey)
+	}
+	if limit > 0 {
+		query += " LIMIT " + strconv.Itoa(limit)
+	}
+	return query, args
+}
+
+// Renders the table template to w, streaming the table's rows as they are read.
+func (t *table) write(w io.Writer) error {
+	if t.d.rowsOnly() {
+		return t.writeRows(w)
+	}
+	if t.rows == nil {
+		return t.d.execute(w, "table", t)
+	}
+
+	// The template stops reading the stream on a write error. The stream is
+	// then cancelled, and the rows only closed once its goroutine is done.
+	ctx := t.ctx
+	var stop context.CancelFunc
+	t.ctx, stop = context.WithCancel(ctx)
+	err := t.d.execute(w, "table", t)
+	stop()
+	t.streaming.Wait()
+	t.ctx = ctx
+	// The rows of later ranges are closed by the stream
+	t.rows.Close()
+	if err != nil {
+		return err
+	}
+	if t.hasValues && !t.streamed {
+		return errors.New("Template did not range over the stream of rows")
+	}
+	if errors.Is(t.err, context.Canceled) && ctx.Err() == nil {
+		return errors.New("Template did not read every row of the stream")
+	}
+	if t.err != nil {
+		return t.err
+	}
+	return t.rows.Err()
+}
+
+// Streams the table's rows as INSERT statement fragments.
+// Any error reading the rows is reported by write once the stream is closed.
+func (t *table) Stream() <-chan string {
+	valueOut := make(chan string)
+	t.streamed = true
+	t.streaming.Add(1)
+	go func() {
+		defer t.streaming.Done()
+		defer close(valueOut)
+		t.err = t.streamValues(valueOut)
+	}()
+	return valueOut
+}
+
+func (t *table) streamValues(valueOut chan<- string) error {
+	send := func(s string) error {
+		if t.d.limiter != nil {
+			if err := t.d.limiter.wait(t.ctx, len(s)); err != nil {
+				return err
+			}
+		}
+		select {
+		case valueOut <- s:
+			return nil
+		case <-t.ctx.Done():
+			return t.ctx.Err()
+		}
+	}
+
+	// Init temp data storage, reused for every row
+	data := make([]sql.RawBytes, len(t.columns))
+	ptrs := make([]interface{}, len(t.columns))
+	for i, _ := range data {
+		ptrs[i] = &data[i]
+	}
+	c := &rangeCursor{t: t, rows: t.rows, index: t.rangeIndex}
+	defer c.close()
+	enc := t.newEncoder()
+	buf := enc.appendHeader(make([]byte, 0, streamChunkSize))
+	if t.hasValues && !t.d.rowsOnly() {
+		buf = t.appendRangeComment(buf, c.index)
+	}
+
+	for ok := t.hasValues; ok; {
+		// Stop a long table promptly once the dump is cancelled
+		if t.progress.Rows%cancelCheckInterval == 0 {
+			if err := t.ctx.Err(); err != nil {
+				return err
+			}
 		}
 
 		// Read data
-		if err := rows.Scan(ptrs...); err != nil {
-			return "", err
+		if err := c.rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		if t.checksum != nil {
+			t.checksum.add(data)
+		}
+		buf = enc.appendRow(buf, data)
+
+		// Rows are sent in chunks rather than one string each
+		if len(buf) >= streamChunkSize {
+			if err := send(string(buf)); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+
+		t.progress.Rows++
+		if t.progress.Rows%progressInterval == 0 {
+			t.d.reportProgress(t.progress)
+		}
+
+		var next bool
+		var err error
+		if ok, next, err = c.next(); err != nil {
+			return err
 		}
-		data_text = append(data_text, "('"+strings.Join(data, "','")+"')")
+		// Each range of WithPrimaryKeyRanges gets its own statements
+		if next && !t.d.rowsOnly() {
+			buf = append(enc.appendFooter(buf), '\n')
+			buf = t.appendRangeComment(buf, c.index)
+			enc = t.newEncoder()
+		}
+	}
+	if t.progress.Rows%progressInterval != 0 {
+		t.d.reportProgress(t.progress)
+	}
+	if buf = enc.appendFooter(buf); len(buf) > 0 {
+		return send(string(buf))
+	}
+	return nil
+}
+
+// Start of each INSERT statement, up to and including the VALUES keyword.
+func (t *table) insertPrefix() string {
+	insert := t.d.insertType.keyword() + " " + t.NameEsc() + " "
+	if t.d.columnNames || t.listed {
+		insert += "(" + t.d.quoteNames(t.columns) + ") "
+	}
+	return insert + "VALUES "
+}
+
+// How the values of a column are written in INSERT statements.
+type valueKind int
+
+const (
+	stringValue valueKind = iota
+	binaryValue
+	numericValue
+	bitValue  // Binary, or a bool literal in dialects with them
+	jsonValue // A string in SQL, kept as a document in JSON output
+)
+
+// Lists columns with their types, as "id INT, name VARCHAR(255)". Lengths and
+// precisions are only included when the driver reports them.
+func typeNames(columns []string, types []*sql.ColumnType) string {
+	names := make([]string, len(types))
+	for i, columnType := range types {
+		name := strings.ToUpper(columnType.DatabaseTypeName())
+		if precision, scale, ok := columnType.DecimalSize(); ok {
+			name += "(" + strconv.FormatInt(precision, 10) + "," + strconv.FormatInt(scale, 10) + ")"
+		} else if length, ok := columnType.Length(); ok && length > 0 && length < math.MaxInt32 {
+			name += "(" + strconv.FormatInt(length, 10) + ")"
+		}
+		// Kept on the comment's line
+		column := strings.NewReplacer("\n", " ", "\r", " ").Replace(columns[i])
+		names[i] = column + " " + name
+	}
+	return strings.Join(names, ", ")
+}
+
+// Picks the value kind for a column from its database type name. Spatial
+// values are read in the server's internal format, the SRID followed by the
+// WKB, which restores as is from a hex literal, keeping the SRID.
+func columnKind(typeName string) valueKind {
+	switch strings.TrimPrefix(strings.ToUpper(typeName), "UNSIGNED ") {
+	case "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB":
+		return binaryValue
+	case "BIT":
+		return bitValue
+	case "GEOMETRY", "POINT", "LINESTRING", "POLYGON", "MULTIPOINT", "MULTILINESTRING", "MULTIPOLYGON",
+		"GEOMETRYCOLLECTION", "GEOMCOLLECTION":
+		return binaryValue
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "DECIMAL", "NUMERIC", "FLOAT", "DOUBLE", "REAL":
+		return numericValue
+	case "JSON":
+		return jsonValue
+	}
+	return stringValue
+}
+
+// End of each INSERT statement, before the terminating ';'. With upserts this
+// updates every column not in the primary key, or all of them if there are none.
+func (t *table) insertSuffix() string {
+	if !t.d.upsert {
+		return ""
+	}
+
+	key := make(map[string]bool, len(t.primaryKey))
+	for _, column := range t.primaryKey {
+		key[column] = true
+	}
+	updates := make([]string, 0, len(t.columns))
+	for _, column := range t.columns {
+		if !key[column] {
+			updates = append(updates, t.d.updateValue(column))
+		}
+	}
+	if len(updates) == 0 {
+		for _, column := range t.columns {
+			updates = append(updates, t.d.updateValue(column))
+		}
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+}
+
+// Appends a scanned row to b as a parenthesised value list, keeping NULL as a literal.
+func (t *table) appendValues(b []byte, data []sql.RawBytes) []byte {
+	dialect := t.d.dialect
+	b = append(b, '(')
+	for i, _ := range data {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		value, kind := t.value(i, data[i])
+		if kind == bitValue {
+			var ok bool
+			if b, ok = dialect.appendBool(b, value); ok {
+				continue
+			}
+			kind = binaryValue
+		}
+		switch {
+		case value == nil:
+			b = append(b, dialect.NullLiteral...)
+		case (kind == binaryValue || hasControlBytes(value)) && len(value) > 0:
+			b = dialect.appendHex(b, value)
+		case kind == numericValue && len(value) > 0:
+			b = append(b, value...)
+		default:
+			b = append(t.appendEscaped(append(b, dialect.StringQuote...), value), dialect.StringQuote...)
+		}
+	}
+	return append(b, ')')
+}
+
+// Reports whether a value has NUL or other control bytes, apart from tabs and
+// line breaks, which some clients mangle even when escaped. Such values are
+// written as hex literals.
+func hasControlBytes(value []byte) bool {
+	for _, c := range value {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' || c == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the i'th value of a row after WithValueTransformer, and how to write it.
+func (t *table) value(i int, value []byte) ([]byte, valueKind) {
+	kind := t.kinds[i]
+	if t.d.transform != nil {
+		var changed bool
+		if value, changed = t.transformValue(t.columns[i], value); changed && kind == numericValue {
+			// Quoted so a changed value can't be read as anything but a value
+			kind = stringValue
+		}
+	}
+	return value, kind
+}
+
+// Applies WithValueTransformer to a value, nil for NULL, and reports whether it changed.
+func (t *table) transformValue(column string, value []byte) ([]byte, bool) {
+	out, null := t.d.transform(t.Name, column, value, value == nil)
+	if null {
+		return nil, value != nil
+	}
+	if out == nil {
+		out = []byte{}
+	}
+	return out, value == nil || !bytes.Equal(out, value)
+}
+
+// Quotes a MYSQL identifier with backticks, doubling any embedded backticks.
+func quoteIdentifier(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+// Quotes each name and joins them with commas, for column lists.
+func quoteList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteIdentifier(name)
 	}
+	return strings.Join(quoted, ", ")
+}
+
+// Assignment of a column's inserted value in ON DUPLICATE KEY UPDATE.
+func (d *Dumper) updateValue(column string) string {
+	return d.quoteName(column) + "=VALUES(" + d.quoteName(column) + ")"
+}
 
-	return strings.Join(data_text, ","), rows.Err()
+// Quotes a name within a database, as `schema`.`name`.
+func qualify(schema, name string) string {
+	return quoteIdentifier(schema) + "." + quoteIdentifier(name)
+}
+
+func (t *table) appendEscaped(b, value []byte) []byte {
+	if t.quoteOnly || !t.d.dialect.BackslashEscapes {
+		quote := []byte(t.d.dialect.StringQuote)
+		for len(value) > 0 {
+			if bytes.HasPrefix(value, quote) {
+				b = append(b, quote...)
+			}
+			b = append(b, value[0])
+			value = value[1:]
+		}
+		return b
+	}
+	return appendEscapedValue(b, value)
+}
+
+// Appends a value escaped for use inside a single quoted MYSQL string literal.
+// Follows the same rules as mysql_real_escape_string, which mysqldump uses.
+func appendEscapedValue(b, value []byte) []byte {
+	for _, c := range value {
+		switch c {
+		case 0:
+			b = append(b, `\0`...)
+		case '\n':
+			b = append(b, `\n`...)
+		case '\r':
+			b = append(b, `\r`...)
+		case '\\':
+			b = append(b, `\\`...)
+		case '\'':
+			b = append(b, `\'`...)
+		case '"':
+			b = append(b, `\"`...)
+		case '\x1a':
+			b = append(b, `\Z`...)
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
 }
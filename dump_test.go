@@ -0,0 +1,1019 @@
+package mysqldump
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+)
+
+const wantDump = "-- Go SQL Dump 0.1.0\n" +
+	"--\n" +
+	"-- ------------------------------------------------------\n" +
+	"-- Server version\t8.0.36\n" +
+	"\n" +
+	"SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT;\n" +
+	"SET @OLD_CHARACTER_SET_RESULTS=@@CHARACTER_SET_RESULTS;\n" +
+	"SET @OLD_COLLATION_CONNECTION=@@COLLATION_CONNECTION;\n" +
+	"SET NAMES utf8mb4;\n" +
+	"SET @OLD_TIME_ZONE=@@TIME_ZONE;\n" +
+	"SET TIME_ZONE='+00:00';\n" +
+	"SET @OLD_SQL_MODE=@@SQL_MODE;\n" +
+	"SET SQL_MODE='NO_AUTO_VALUE_ON_ZERO';\n" +
+	"SET FOREIGN_KEY_CHECKS=0;\n" +
+	"SET UNIQUE_CHECKS=0;\n" +
+	"\n" +
+	"\n" +
+	"\n" +
+	"--\n" +
+	"-- Table structure for table users\n" +
+	"--\n" +
+	"\n" +
+	"DROP TABLE IF EXISTS `users`;\n" +
+	"CREATE TABLE `users` (\n" +
+	"  `id` int,\n" +
+	"  `name` varchar,\n" +
+	"  `data` blob\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;\n" +
+	"\n" +
+	"--\n" +
+	"-- Dumping data for table users\n" +
+	"--\n" +
+	"\n" +
+	"LOCK TABLES `users` WRITE;\n" +
+	"INSERT INTO `users` VALUES (1,'O\\'Brien',0x00ff),(2,NULL,NULL);\n" +
+	"UNLOCK TABLES;\n" +
+	"\n" +
+	"SET FOREIGN_KEY_CHECKS=1;\n" +
+	"SET UNIQUE_CHECKS=1;\n" +
+	"SET SQL_MODE=@OLD_SQL_MODE;\n" +
+	"SET TIME_ZONE=@OLD_TIME_ZONE;\n" +
+	"SET CHARACTER_SET_CLIENT=@OLD_CHARACTER_SET_CLIENT;\n" +
+	"SET CHARACTER_SET_RESULTS=@OLD_CHARACTER_SET_RESULTS;\n" +
+	"SET COLLATION_CONNECTION=@OLD_COLLATION_CONNECTION;\n" +
+	"\n" +
+	"-- Dump completed on 2024-01-02T03:04:05Z\n"
+
+func TestDumpTo(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	if got := dumpString(t, f); got != wantDump {
+		t.Errorf("DumpTo wrote:\n%s\nwant:\n%s", got, wantDump)
+	}
+}
+
+func TestDumpEmptyString(t *testing.T) {
+	f := newFakeDB()
+	f.addTable("notes", []string{"id", "note"}, []string{"INT", "VARCHAR"},
+		fakeRow(int64(1), ""),
+		fakeRow(int64(2), []byte{}),
+		fakeRow(int64(3), nil))
+	want := "INSERT INTO `notes` VALUES (1,''),(2,''),(3,NULL);\n"
+	if got := dumpString(t, f); !strings.Contains(got, want) {
+		t.Errorf("DumpTo wrote:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDumpTablesWithoutData(t *testing.T) {
+	f := newFakeDB()
+	f.addTable("nothing", nil, nil)
+	f.addTable("empty", []string{"id"}, []string{"INT"})
+	f.addTable("computed", []string{"total"}, []string{"INT"}, fakeRow(int64(1)))
+	f.set(fmt.Sprint("SELECT COLUMN_NAME, EXTRA FROM information_schema.COLUMNS "+
+		"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", []driver.Value{"db", "computed"}),
+		[]string{"COLUMN_NAME", "EXTRA"}, fakeRow("total", "VIRTUAL GENERATED"))
+	got := dumpString(t, f)
+	for _, name := range []string{"nothing", "empty", "computed"} {
+		if !strings.Contains(got, "CREATE TABLE `"+name+"`") {
+			t.Errorf("dump doesn't have the structure of %s:\n%s", name, got)
+		}
+	}
+	if strings.Contains(got, "INSERT") || strings.Contains(got, "LOCK TABLES") {
+		t.Errorf("dump has rows of tables without data:\n%s", got)
+	}
+	for _, query := range f.queries() {
+		if strings.HasPrefix(query, "SELECT `total`") || strings.HasPrefix(query, "SELECT  FROM") {
+			t.Errorf("read rows of a table without dumped columns with %q", query)
+		}
+	}
+}
+
+func TestDumpOptions(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(f *fakeDB)
+		opts  []Option
+		want  []string
+		not   []string
+	}{
+		{
+			name: "no extended insert",
+			opts: []Option{WithExtendedInsert(false)},
+			want: []string{"INSERT INTO `users` VALUES (1,'O\\'Brien',0x00ff);\nINSERT INTO `users` VALUES (2,NULL,NULL);\n"},
+		},
+		{
+			name: "insert batch rows",
+			opts: []Option{WithInsertBatchRows(1)},
+			want: []string{"VALUES (1,'O\\'Brien',0x00ff);\nINSERT INTO `users` VALUES (2,NULL,NULL);\n"},
+		},
+		{
+			name: "max packet",
+			opts: []Option{WithMaxPacket(40)},
+			want: []string{"VALUES (1,'O\\'Brien',0x00ff);\nINSERT INTO `users` VALUES (2,NULL,NULL);\n"},
+		},
+		{
+			name: "insert ignore",
+			opts: []Option{WithInsertType(InsertIgnore)},
+			want: []string{"INSERT IGNORE INTO `users` VALUES"},
+		},
+		{
+			name: "replace",
+			opts: []Option{WithInsertType(Replace)},
+			want: []string{"REPLACE INTO `users` VALUES"},
+		},
+		{
+			name: "column names",
+			opts: []Option{WithColumnNames(true)},
+			want: []string{"INSERT INTO `users` (`id`, `name`, `data`) VALUES (1,"},
+		},
+		{
+			name:  "upsert",
+			setup: func(f *fakeDB) { f.setPrimaryKey("users", "id") },
+			opts:  []Option{WithUpsert(true)},
+			want:  []string{"(2,NULL,NULL) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`), `data`=VALUES(`data`);\n"},
+		},
+		{
+			name: "no data",
+			opts: []Option{WithNoData(true)},
+			want: []string{"CREATE TABLE `users`"},
+			not:  []string{"INSERT", "LOCK TABLES"},
+		},
+		{
+			name: "no create info",
+			opts: []Option{WithNoCreateInfo(true)},
+			want: []string{"INSERT INTO `users`"},
+			not:  []string{"CREATE TABLE", "DROP TABLE"},
+		},
+		{
+			name: "no drop table",
+			opts: []Option{WithDropTable(false)},
+			not:  []string{"DROP TABLE"},
+		},
+		{
+			name: "truncate",
+			opts: []Option{WithTruncate(true)},
+			want: []string{"CREATE TABLE IF NOT EXISTS `users`", "\nTRUNCATE TABLE `users`;\n"},
+			not:  []string{"DROP TABLE"},
+		},
+		{
+			name: "create if not exists",
+			opts: []Option{WithCreateIfNotExists(true)},
+			want: []string{"DROP TABLE IF EXISTS `users`;\nCREATE TABLE IF NOT EXISTS `users`"},
+		},
+		{
+			name: "no disable checks",
+			opts: []Option{WithDisableChecks(false)},
+			not:  []string{"FOREIGN_KEY_CHECKS", "UNIQUE_CHECKS"},
+		},
+		{
+			name: "no lock tables",
+			opts: []Option{WithLockTables(false)},
+			not:  []string{"LOCK TABLES"},
+		},
+		{
+			name: "consistent snapshot",
+			opts: []Option{WithConsistentSnapshot(true)},
+			not:  []string{"LOCK TABLES"},
+		},
+		{
+			name: "charset",
+			opts: []Option{WithCharset("latin1")},
+			want: []string{"SET NAMES latin1;\n"},
+		},
+		{
+			name: "header comment",
+			opts: []Option{WithHeaderComment("job 1\nsecond\x00line")},
+			want: []string{"-- Server version\t8.0.36\n-- job 1\n-- second line\n\nSET"},
+		},
+		{
+			name: "no comments",
+			opts: []Option{WithComments(false)},
+			want: []string{"SET @OLD_CHARACTER_SET_CLIENT", "INSERT INTO `users`"},
+			not:  []string{"--"},
+		},
+		{
+			name: "header comment without comments",
+			opts: []Option{WithComments(false), WithHeaderComment("job 1")},
+			want: []string{"-- job 1\n\nSET"},
+			not:  []string{"Server version"},
+		},
+		{
+			name: "server version",
+			opts: []Option{WithServerVersion("hidden\nversion")},
+			want: []string{"-- Server version\thidden version\n"},
+			not:  []string{"8.0.36"},
+		},
+		{
+			name: "column type comments",
+			opts: []Option{WithColumnTypeComments(true)},
+			want: []string{"-- columns: id INT, name VARCHAR, data BLOB\nLOCK TABLES"},
+		},
+		{
+			name: "no quote names",
+			opts: []Option{WithQuoteNames(false), WithColumnNames(true)},
+			want: []string{"INSERT INTO users (id, name, data) VALUES", "LOCK TABLES users WRITE;"},
+		},
+		{
+			name: "table rename",
+			opts: []Option{WithTableRename(func(name string) string { return "old_" + name })},
+			want: []string{"DROP TABLE IF EXISTS `old_users`;\nCREATE TABLE `old_users` (", "INSERT INTO `old_users` VALUES"},
+		},
+		{
+			name: "table option",
+			opts: []Option{WithTableOption("ENGINE", "MyISAM")},
+			want: []string{") ENGINE=MyISAM DEFAULT CHARSET=utf8mb4"},
+		},
+		{
+			name: "sqlite dialect",
+			opts: []Option{WithDialect(DialectSQLite)},
+			want: []string{`INSERT INTO "users" VALUES (1,'O''Brien',X'00ff'),(2,NULL,NULL);`},
+		},
+		{
+			name: "no backslash escapes",
+			setup: func(f *fakeDB) {
+				f.set("SELECT @@SESSION.sql_mode", []string{"mode"}, fakeRow("ANSI_QUOTES,NO_BACKSLASH_ESCAPES"))
+			},
+			want: []string{"SET SQL_MODE='NO_AUTO_VALUE_ON_ZERO,NO_BACKSLASH_ESCAPES';", "(1,'O''Brien',0x00ff)"},
+		},
+		{
+			name: "value transformer",
+			opts: []Option{WithValueTransformer(func(table, column string, value []byte, null bool) ([]byte, bool) {
+				if column == "id" {
+					return []byte("7"), false
+				}
+				return value, null
+			})},
+			want: []string{"VALUES ('7','O\\'Brien',0x00ff),('7',NULL,NULL);"},
+		},
+		{
+			name: "disable keys",
+			setup: func(f *fakeDB) {
+				f.set("SELECT COUNT(*) FROM information_schema.STATISTICS *", []string{"COUNT(*)"}, fakeRow(int64(1)))
+			},
+			opts: []Option{WithDisableKeys(true)},
+			want: []string{"LOCK TABLES `users` WRITE;\nALTER TABLE `users` DISABLE KEYS;\nINSERT", ";\nALTER TABLE `users` ENABLE KEYS;\nUNLOCK TABLES;"},
+		},
+		{
+			name: "disable keys without non-unique indexes",
+			setup: func(f *fakeDB) {
+				f.set("SELECT COUNT(*) FROM information_schema.STATISTICS *", []string{"COUNT(*)"}, fakeRow(int64(0)))
+			},
+			opts: []Option{WithDisableKeys(true)},
+			not:  []string{"DISABLE KEYS"},
+		},
+		{
+			name: "master data",
+			setup: func(f *fakeDB) {
+				f.set("SHOW MASTER STATUS", []string{"File", "Position", "Binlog_Do_DB"}, fakeRow("binlog.000001", int64(157), ""))
+			},
+			opts: []Option{WithMasterData(true)},
+			want: []string{"\n-- CHANGE MASTER TO MASTER_LOG_FILE='binlog.000001', MASTER_LOG_POS=157;\n"},
+		},
+		{
+			name: "change master",
+			setup: func(f *fakeDB) {
+				f.set("SHOW MASTER STATUS", []string{"File", "Position"}, fakeRow("binlog.000001", int64(157)))
+			},
+			opts: []Option{WithMasterData(true), WithChangeMaster(true)},
+			want: []string{"\nCHANGE MASTER TO MASTER_LOG_FILE='binlog.000001', MASTER_LOG_POS=157;\n"},
+		},
+		{
+			name: "change master without comments",
+			setup: func(f *fakeDB) {
+				f.set("SHOW MASTER STATUS", []string{"File", "Position"}, fakeRow("binlog.000001", int64(157)))
+			},
+			opts: []Option{WithMasterData(true), WithChangeMaster(true), WithComments(false)},
+			want: []string{"\nCHANGE MASTER TO MASTER_LOG_FILE='binlog.000001', MASTER_LOG_POS=157;\n"},
+			not:  []string{"-- Position"},
+		},
+		{
+			name: "checksums",
+			opts: []Option{WithChecksums(true)},
+			want: []string{"\n-- Checksum db.users 2 "},
+		},
+		{
+			name: "create database",
+			opts: []Option{WithCreateDatabase(true)},
+			want: []string{"CREATE DATABASE /*!32312 IF NOT EXISTS*/ `db`", ";\n\nUSE `db`;\n"},
+		},
+		{
+			name: "triggers",
+			setup: func(f *fakeDB) {
+				f.set("SELECT TRIGGER_NAME FROM information_schema.TRIGGERS *", []string{"TRIGGER_NAME"}, fakeRow("ins"))
+				f.set("SHOW CREATE TRIGGER `db`.`ins`", []string{"Trigger", "sql_mode", "SQL Original Statement"},
+					fakeRow("ins", "", "CREATE DEFINER=`root`@`%` TRIGGER ins BEFORE INSERT ON users FOR EACH ROW SET @n = 1"))
+			},
+			opts: []Option{WithTriggers(true), WithStripDefiners(true)},
+			want: []string{"DELIMITER ;;\nCREATE TRIGGER ins BEFORE INSERT ON users FOR EACH ROW SET @n = 1;;\nDELIMITER ;\n"},
+		},
+		{
+			name: "views",
+			setup: func(f *fakeDB) {
+				f.set("SHOW FULL TABLES FROM `db` WHERE Table_type = 'VIEW'", []string{"Tables_in_db", "Table_type"}, fakeRow("names", "VIEW"))
+				f.set("SHOW CREATE VIEW `db`.`names`", []string{"View", "Create View", "character_set_client", "collation_connection"},
+					fakeRow("names", "CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`%` SQL SECURITY DEFINER VIEW `names` AS select `name` from `users`", "utf8mb4", "utf8mb4_0900_ai_ci"))
+			},
+			want: []string{"UNLOCK TABLES;\n\n--\n-- View structure for view names\n--\n\nDROP VIEW IF EXISTS `names`;\nCREATE ALGORITHM=UNDEFINED DEFINER="},
+		},
+		{
+			name: "routines",
+			setup: func(f *fakeDB) {
+				f.set("SELECT ROUTINE_TYPE, ROUTINE_NAME FROM information_schema.ROUTINES *", []string{"ROUTINE_TYPE", "ROUTINE_NAME"}, fakeRow("FUNCTION", "one"))
+				f.set("SHOW CREATE FUNCTION `db`.`one`", []string{"Function", "sql_mode", "Create Function"},
+					fakeRow("one", "", "CREATE FUNCTION `one`() RETURNS int RETURN 1"))
+			},
+			opts: []Option{WithRoutines(true)},
+			want: []string{"DELIMITER ;;\nDROP FUNCTION IF EXISTS `one`;;\nCREATE FUNCTION `one`() RETURNS int RETURN 1;;\nDELIMITER ;\n"},
+		},
+		{
+			name: "events",
+			setup: func(f *fakeDB) {
+				f.set("SELECT EVENT_NAME FROM information_schema.EVENTS *", []string{"EVENT_NAME"}, fakeRow("tick"))
+				f.set("SHOW CREATE EVENT `db`.`tick`", []string{"Event", "sql_mode", "time_zone", "Create Event"},
+					fakeRow("tick", "", "UTC", "CREATE EVENT `tick` ON SCHEDULE EVERY 1 DAY DO SELECT 1"))
+			},
+			opts: []Option{WithEvents(true)},
+			want: []string{"DROP EVENT IF EXISTS `tick`;;\nCREATE EVENT `tick` ON SCHEDULE EVERY 1 DAY DO SELECT 1;;\n"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeDB()
+			f.addUsers()
+			if test.setup != nil {
+				test.setup(f)
+			}
+			got := dumpString(t, f, test.opts...)
+			for _, want := range test.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("dump doesn't contain %q:\n%s", want, got)
+				}
+			}
+			for _, not := range test.not {
+				if strings.Contains(got, not) {
+					t.Errorf("dump contains %q:\n%s", not, got)
+				}
+			}
+		})
+	}
+}
+
+func TestTableFilters(t *testing.T) {
+	tests := []struct {
+		name             string
+		include, exclude []string
+		want             string
+	}{
+		{"all", nil, nil, "users,log_a,log_b,logs,log_[x]"},
+		{"include pattern", []string{"log_*"}, nil, "log_a,log_b,log_[x]"},
+		{"include names", []string{"users", "logs"}, nil, "users,logs"},
+		{"exclude pattern", nil, []string{"log_?"}, "users,logs,log_[x]"},
+		{"exclude wins", []string{"log*", "users"}, []string{"log_b", "logs"}, "users,log_a,log_[x]"},
+		{"escaped bracket", []string{`log_\[x\]`}, nil, "log_[x]"},
+		{"no match", []string{"orders"}, nil, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeDB()
+			for _, name := range []string{"users", "log_a", "log_b", "logs", "log_[x]"} {
+				f.addTable(name, []string{"id"}, []string{"INT"})
+			}
+			got := dumpString(t, f, WithIncludeTables(test.include...), WithExcludeTables(test.exclude...))
+			var tables []string
+			for _, line := range strings.Split(got, "\n") {
+				if name, ok := strings.CutPrefix(line, "CREATE TABLE `"); ok {
+					tables = append(tables, strings.TrimSuffix(name, "` ("))
+				}
+			}
+			if strings.Join(tables, ",") != test.want {
+				t.Errorf("dumped tables %s, want %s", strings.Join(tables, ","), test.want)
+			}
+		})
+	}
+}
+
+func TestSelectQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(f *fakeDB)
+		opts  []Option
+		want  string
+	}{
+		{
+			name: "default",
+			want: "SELECT `id`, `name`, `data` FROM `db`.`users`",
+		},
+		{
+			name: "where",
+			opts: []Option{WithWhere("users", "id > 1")},
+			want: "SELECT `id`, `name`, `data` FROM `db`.`users` WHERE id > 1",
+		},
+		{
+			name:  "order by primary key",
+			setup: func(f *fakeDB) { f.setPrimaryKey("users", "id", "name") },
+			opts:  []Option{WithOrderByPrimaryKey(true)},
+			want:  "SELECT `id`, `name`, `data` FROM `db`.`users` ORDER BY `id`, `name`",
+		},
+		{
+			name: "row limit",
+			opts: []Option{WithRowLimit(10)},
+			want: "SELECT `id`, `name`, `data` FROM `db`.`users` LIMIT 10",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeDB()
+			f.addUsers()
+			if test.setup != nil {
+				test.setup(f)
+			}
+			dumpString(t, f, test.opts...)
+			var selects []string
+			for _, query := range f.queries() {
+				if strings.HasPrefix(query, "SELECT `") {
+					selects = append(selects, query)
+				}
+			}
+			if len(selects) != 1 || selects[0] != test.want {
+				t.Errorf("read rows with %q, want %q", selects, test.want)
+			}
+		})
+	}
+}
+
+func TestAppendEscapedValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"plain text", "plain text"},
+		{"it's", `it\'s`},
+		{`say "hi"`, `say \"hi\"`},
+		{`C:\dir`, `C:\\dir`},
+		{"a\nb\rc", `a\nb\rc`},
+		{"nul\x00", `nul\0`},
+		{"ctrl-z\x1a", `ctrl-z\Z`},
+		{"tab\tkept", "tab\tkept"},
+		{"ünïcødé", "ünïcødé"},
+	}
+	for _, test := range tests {
+		if got := string(appendEscapedValue(nil, []byte(test.value))); got != test.want {
+			t.Errorf("appendEscapedValue(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"users", "`users`"},
+		{"order", "`order`"},
+		{"with space", "`with space`"},
+		{"back`tick", "`back``tick`"},
+		{"", "``"},
+	}
+	for _, test := range tests {
+		if got := quoteIdentifier(test.name); got != test.want {
+			t.Errorf("quoteIdentifier(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+	if got, want := qualify("d`b", "t"), "`d``b`.`t`"; got != want {
+		t.Errorf("qualify = %q, want %q", got, want)
+	}
+}
+
+func TestAppendValues(t *testing.T) {
+	tests := []struct {
+		name      string
+		dialect   Dialect
+		quoteOnly bool
+		kinds     []valueKind
+		values    [][]byte
+		want      string
+	}{
+		{
+			name:   "mysql",
+			kinds:  []valueKind{numericValue, stringValue, binaryValue, stringValue},
+			values: [][]byte{[]byte("-1.5"), []byte("a'b"), {0xde, 0xad}, nil},
+			want:   `(-1.5,'a\'b',0xdead,NULL)`,
+		},
+		{
+			name:   "empty values",
+			kinds:  []valueKind{numericValue, stringValue, binaryValue},
+			values: [][]byte{{}, {}, {}},
+			want:   `('','','')`,
+		},
+		{
+			name:   "control bytes as hex",
+			kinds:  []valueKind{stringValue, stringValue},
+			values: [][]byte{[]byte("a\x01b"), []byte("line\nbreak")},
+			want:   `(0x610162,'line\nbreak')`,
+		},
+		{
+			name:      "quotes only",
+			quoteOnly: true,
+			kinds:     []valueKind{stringValue},
+			values:    [][]byte{[]byte(`it's a \`)},
+			want:      `('it''s a \')`,
+		},
+		{
+			name:   "bit",
+			kinds:  []valueKind{bitValue, bitValue},
+			values: [][]byte{{1}, {0, 5}},
+			want:   `(0x01,0x0005)`,
+		},
+		{
+			name:    "sqlite",
+			dialect: DialectSQLite,
+			kinds:   []valueKind{bitValue, bitValue, binaryValue, stringValue},
+			values:  [][]byte{{1}, {0}, {0xff}, []byte(`it's \`)},
+			want:    `(1,0,X'ff','it''s \')`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := &Dumper{dialect: DialectMySQL}
+			if test.dialect.valid() {
+				d.dialect = test.dialect
+			}
+			tbl := &table{d: d, kinds: test.kinds, quoteOnly: test.quoteOnly}
+			data := make([]sql.RawBytes, len(test.values))
+			for i, value := range test.values {
+				data[i] = value
+			}
+			if got := string(tbl.appendValues(nil, data)); got != test.want {
+				t.Errorf("appendValues = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestColumnKind(t *testing.T) {
+	tests := []struct {
+		typeName string
+		want     valueKind
+	}{
+		{"INT", numericValue},
+		{"UNSIGNED BIGINT", numericValue},
+		{"decimal", numericValue},
+		{"VARCHAR", stringValue},
+		{"DATETIME", stringValue},
+		{"BLOB", binaryValue},
+		{"VARBINARY", binaryValue},
+		{"GEOMETRY", binaryValue},
+		{"BIT", bitValue},
+		{"JSON", jsonValue},
+	}
+	for _, test := range tests {
+		if got := columnKind(test.typeName); got != test.want {
+			t.Errorf("columnKind(%q) = %v, want %v", test.typeName, got, test.want)
+		}
+	}
+}
+
+func TestCreateIfNotExists(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"CREATE TABLE `t` (id int)", "CREATE TABLE IF NOT EXISTS `t` (id int)"},
+		{"CREATE TABLE IF NOT EXISTS `t` (id int)", "CREATE TABLE IF NOT EXISTS `t` (id int)"},
+		{"CREATE TEMPORARY TABLE `t` (id int)", "CREATE TEMPORARY TABLE `t` (id int)"},
+	}
+	for _, test := range tests {
+		if got := createIfNotExists(test.sql); got != test.want {
+			t.Errorf("createIfNotExists(%q) = %q, want %q", test.sql, got, test.want)
+		}
+	}
+}
+
+func TestGetColumns(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	f.set("SELECT COLUMN_NAME, EXTRA FROM information_schema.COLUMNS *", []string{"COLUMN_NAME", "EXTRA"},
+		fakeRow("id", "auto_increment"),
+		fakeRow("total", "VIRTUAL GENERATED"),
+		fakeRow("created", "DEFAULT_GENERATED"),
+		fakeRow("secret", "INVISIBLE"))
+	f.mu.Lock()
+	delete(f.results, "SELECT COLUMN_NAME, EXTRA FROM information_schema.COLUMNS "+
+		"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION[db users]")
+	f.mu.Unlock()
+
+	columns, listed, err := getColumns(context.Background(), newTestDumper(t, f).db, "db", "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(columns, ","), "id,created,secret"; got != want || !listed {
+		t.Errorf("getColumns = %s, %v, want %s, true", got, listed, want)
+	}
+}
+
+func TestCustomTemplate(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	custom := template.Must(template.New("custom").Parse(`{{ define "header" }}-- {{ .ServerVersion }}
+{{ end }}{{ define "table" }}{{ .NameEsc }}:{{ range .Stream }}{{ . }}{{ end }}
+{{ end }}{{ define "footer" }}-- end
+{{ end }}`))
+	got := dumpString(t, f, WithTemplate(custom))
+	want := "-- 8.0.36\n`users`:INSERT INTO `users` VALUES (1,'O\\'Brien',0x00ff),(2,NULL,NULL);\n-- end\n"
+	if got != want {
+		t.Errorf("custom template wrote %q, want %q", got, want)
+	}
+}
+
+func TestCustomTemplateStopsStream(t *testing.T) {
+	f := newFakeDB()
+	addLargeTable(f, "large", 2000)
+	custom := template.Must(template.New("custom").Parse(`{{ define "header" }}{{ end }}` +
+		`{{ define "table" }}{{ range .Stream }}{{ . }}{{ break }}{{ end }}{{ end }}{{ define "footer" }}{{ end }}`))
+	var b strings.Builder
+	err := newTestDumper(t, f, WithTemplate(custom)).DumpTo(&b)
+	if err == nil || !strings.Contains(err.Error(), "did not read every row") {
+		t.Errorf("DumpTo = %v, want did not read every row", err)
+	}
+}
+
+func TestCustomTemplateIgnoresStream(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	custom := template.Must(template.New("custom").Parse(`{{ define "header" }}{{ end }}` +
+		`{{ define "table" }}{{ .NameEsc }}{{ end }}{{ define "footer" }}{{ end }}`))
+	var b strings.Builder
+	err := newTestDumper(t, f, WithTemplate(custom)).DumpTo(&b)
+	if err == nil || !strings.Contains(err.Error(), "did not range over the stream") {
+		t.Errorf("DumpTo = %v, want did not range over the stream", err)
+	}
+}
+
+// Fails every write after limit bytes.
+type failingWriter struct {
+	limit int
+	n     int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n+len(p) > w.limit {
+		return 0, errWriteFailed
+	}
+	w.n += len(p)
+	return len(p), nil
+}
+
+func TestDumpToWriteError(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	err := newTestDumper(t, f).DumpTo(&failingWriter{limit: 10})
+	if !errors.Is(err, errWriteFailed) {
+		t.Errorf("DumpTo = %v, want %v", err, errWriteFailed)
+	}
+}
+
+func TestDumpTable(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	f.addTable("other", []string{"id"}, []string{"INT"}, fakeRow(int64(1)))
+	var b strings.Builder
+	if err := newTestDumper(t, f).DumpTable(context.Background(), &b, "other"); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); !strings.Contains(got, "INSERT INTO `other` VALUES (1);") || strings.Contains(got, "users") {
+		t.Errorf("DumpTable wrote:\n%s", got)
+	}
+	if f.ran("SHOW FULL TABLES") {
+		t.Error("DumpTable listed the tables")
+	}
+}
+
+func TestDump(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	d := newTestDumper(t, f)
+	if err := d.Dump(); err != nil {
+		t.Fatal(err)
+	}
+	p := path.Join(d.dir, "dump.sql")
+	data, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != wantDump {
+		t.Errorf("Dump wrote:\n%s", data)
+	}
+	if info, err := os.Stat(p); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("dump file mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+	if e, _ := exists(p + ".partial"); e {
+		t.Error("partial dump file left behind")
+	}
+
+	// The clock gives the same name again
+	if err := d.Dump(); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("second Dump = %v, want already exists", err)
+	}
+	d.uniqueSuffix = true
+	if err := d.Dump(); err != nil {
+		t.Fatal(err)
+	}
+	if !isFile(path.Join(d.dir, "dump-1.sql")) {
+		t.Error("WithUniqueSuffix didn't write dump-1.sql")
+	}
+}
+
+func TestDumpKeepsFileCreatedMeanwhile(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		file string
+	}{
+		{"single file", nil, "dump.sql"},
+		{"max file size", []Option{WithMaxFileSize(1 << 20)}, "dump.001.sql"},
+		{"file per table", []Option{WithFilePerTable(true)}, "dump"},
+		{"archive", []Option{WithFilePerTable(true), WithArchive(true)}, "dump.tar"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeDB()
+			f.addUsers()
+			d := newTestDumper(t, f, test.opts...)
+			p := path.Join(d.dir, test.file)
+			// Another dump takes the name while the rows are read
+			f.handle = func(query string, args []driver.Value) (fakeResult, bool) {
+				if strings.HasPrefix(query, "SELECT `id`") {
+					os.WriteFile(p, []byte("other"), 0600)
+				}
+				return fakeResult{}, false
+			}
+			if err := d.Dump(); !errors.Is(err, fs.ErrExist) {
+				t.Errorf("Dump = %v, want %v", err, fs.ErrExist)
+			}
+			if data, err := os.ReadFile(p); err != nil || string(data) != "other" {
+				t.Errorf("file created meanwhile holds %q, %v", data, err)
+			}
+		})
+	}
+}
+
+func TestDumpFailureRemovesPartial(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	f.fail("SELECT `id`, `name`, `data` FROM `db`.`users`*", errors.New("read failed"))
+	d := newTestDumper(t, f)
+	if err := d.Dump(); err == nil {
+		t.Fatal("Dump succeeded")
+	}
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) > 0 {
+		t.Errorf("failed dump left %s behind", entries[0].Name())
+	}
+}
+
+func TestDumpContextCancelled(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := newTestDumper(t, f).DumpContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("DumpContext = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestDumpTimeout(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	// Reading the rows takes longer than the timeout
+	f.handle = func(query string, args []driver.Value) (fakeResult, bool) {
+		if strings.HasPrefix(query, "SELECT `id`") {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return fakeResult{}, false
+	}
+	d := newTestDumper(t, f, WithTimeout(10*time.Millisecond))
+	if err := d.Dump(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Dump = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if entries, _ := os.ReadDir(d.dir); len(entries) > 0 {
+		t.Errorf("dump that timed out left %s behind", entries[0].Name())
+	}
+}
+
+func TestDumpCancelledMidTable(t *testing.T) {
+	f := newFakeDB()
+	addLargeTable(f, "large", 5000)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var rows int64
+	d := newTestDumper(t, f, WithProgress(func(event ProgressEvent) {
+		rows = event.Rows
+		if event.Rows == 1000 {
+			cancel()
+		}
+	}))
+	if err := d.DumpContext(ctx); !errors.Is(err, ctx.Err()) || ctx.Err() == nil {
+		t.Errorf("DumpContext = %v, want %v", err, ctx.Err())
+	}
+	if rows >= 5000 {
+		t.Errorf("dump read all %d rows after it was cancelled", rows)
+	}
+	if entries, _ := os.ReadDir(d.dir); len(entries) > 0 {
+		t.Errorf("cancelled dump left %s behind", entries[0].Name())
+	}
+}
+
+func TestReader(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	r := newTestDumper(t, f).Reader(context.Background())
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != wantDump {
+		t.Errorf("Reader read:\n%s", data)
+	}
+}
+
+func TestReaderClosedEarly(t *testing.T) {
+	f := newFakeDB()
+	addLargeTable(f, "large", 5000)
+	f.addUsers()
+	var mu sync.Mutex
+	var events int
+	r := newTestDumper(t, f, WithProgress(func(ProgressEvent) {
+		mu.Lock()
+		events++
+		mu.Unlock()
+	})).Reader(context.Background())
+	if _, err := io.ReadFull(r, make([]byte, 40<<10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The dump has stopped once Close returns
+	mu.Lock()
+	n, queries := events, len(f.queries())
+	mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if events != n || len(f.queries()) != queries {
+		t.Error("dump still running after Close returned")
+	}
+	if f.ran("SELECT `id`, `name`, `data` FROM `db`.`users`") {
+		t.Error("dump went on to the next table")
+	}
+}
+
+func TestDumpWithStats(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	f.addTable("empty", []string{"id"}, []string{"INT"})
+	stats, err := newTestDumper(t, f).DumpWithStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Tables) != 2 || stats.Tables[0].Rows != 2 || stats.Tables[1].Rows != 0 || stats.Rows != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if stats.Bytes <= 0 {
+		t.Errorf("stats.Bytes = %d", stats.Bytes)
+	}
+}
+
+// Adds a table with n rows of about 100 bytes each.
+func addLargeTable(f *fakeDB, name string, n int) {
+	rows := make([][]driver.Value, n)
+	for i := range rows {
+		rows[i] = fakeRow(int64(i+1), strings.Repeat("x", 90), []byte{byte(i)})
+	}
+	f.addTable(name, []string{"id", "name", "data"}, []string{"INT", "VARCHAR", "BLOB"}, rows...)
+}
+
+func TestOrderByPrimaryKeyIsStable(t *testing.T) {
+	f := newFakeDB()
+	addLargeTable(f, "large", 50)
+	f.setPrimaryKey("large", "id")
+	sorted, _ := f.lookup("SELECT `id`, `name`, `data` FROM `db`.`large`", nil)
+	// Like a server, returns the rows in a different order each time unless ordered
+	reads := 0
+	f.handle = func(query string, args []driver.Value) (fakeResult, bool) {
+		if !strings.HasPrefix(query, "SELECT `id`, `name`, `data` FROM `db`.`large`") {
+			return fakeResult{}, false
+		}
+		if strings.HasSuffix(query, " ORDER BY `id`") {
+			return sorted, true
+		}
+		reads++
+		r := sorted
+		r.rows = append(append([][]driver.Value(nil), sorted.rows[reads:]...), sorted.rows[:reads]...)
+		return r, true
+	}
+	if dumpString(t, f) == dumpString(t, f) {
+		t.Fatal("fake returned the rows in the same order twice")
+	}
+	first := dumpString(t, f, WithOrderByPrimaryKey(true))
+	if second := dumpString(t, f, WithOrderByPrimaryKey(true)); first != second {
+		t.Errorf("dumps ordered by primary key differ:\n%s\n%s", first, second)
+	}
+	if !strings.Contains(first, "VALUES (1,'") {
+		t.Errorf("dump ordered by primary key doesn't start with row 1:\n%s", first)
+	}
+}
+
+func TestDumpRowLimit(t *testing.T) {
+	f := newFakeDB()
+	addLargeTable(f, "large", 1000)
+	got := dumpString(t, f, WithRowLimit(10))
+	if n := strings.Count(got, "),(") + 1; n != 10 {
+		t.Errorf("dump has %d rows, want 10", n)
+	}
+	if !strings.Contains(got, "(10,'") || strings.Contains(got, "(11,'") {
+		t.Errorf("dump doesn't end with row 10:\n%s", got)
+	}
+}
+
+func TestStreamChunks(t *testing.T) {
+	f := newFakeDB()
+	addLargeTable(f, "large", 2000)
+	got := dumpString(t, f)
+	if n := strings.Count(got, "),("); n != 1999 {
+		t.Errorf("dump has %d rows in extended inserts, want 2000", n+1)
+	}
+	if !bytes.Contains([]byte(got), []byte("(2000,'"+strings.Repeat("x", 90)+"',0xcf);\n")) {
+		t.Error("last row missing")
+	}
+}
+
+func TestStreamStoppedByWriteError(t *testing.T) {
+	f := newFakeDB()
+	addLargeTable(f, "large", 5000)
+	var mu sync.Mutex
+	var events int
+	d := newTestDumper(t, f, WithProgress(func(ProgressEvent) {
+		mu.Lock()
+		events++
+		mu.Unlock()
+	}))
+	err := d.DumpTo(&failingWriter{limit: 40 << 10})
+	if !errors.Is(err, errWriteFailed) {
+		t.Fatalf("DumpTo = %v, want %v", err, errWriteFailed)
+	}
+
+	// The rows have stopped streaming once DumpTo returns
+	mu.Lock()
+	n := events
+	mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if events != n {
+		t.Errorf("%d progress events after DumpTo returned", events-n)
+	}
+}
+
+// Benchmarks rendering the rows of a table, see streamValues.
+func BenchmarkDumpTo(b *testing.B) {
+	f := newFakeDB()
+	addLargeTable(f, "large", 10000)
+	d := newTestDumper(b, f)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := d.DumpTo(io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
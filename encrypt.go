@@ -0,0 +1,187 @@
+package mysqldump
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Start of every encrypted dump, followed by the nonce prefix.
+const encryptionMagic = "GOSQLENC\x01"
+
+const (
+	encryptionChunk = 64 << 10 // Plaintext bytes per sealed chunk
+	noncePrefixSize = 7        // Random part of each chunk's nonce
+)
+
+// Encrypts dumps with AES-GCM using key, which must be 16, 24 or 32 bytes for
+// AES-128, AES-192 or AES-256. Data is compressed before it is encrypted. Dump
+// files get an extra '.enc' suffix. Read them back with DecryptReader.
+func WithEncryption(key []byte) Option {
+	return func(d *Dumper) {
+		d.encryptionKey = key
+	}
+}
+
+/*
+Returns a reader decrypting a dump written with WithEncryption. Fails when
+reading data that was modified or cut short.
+
+	r: Reader for the encrypted dump.
+	key: Key the dump was encrypted with.
+*/
+func DecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len(encryptionMagic)+noncePrefixSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if string(header[:len(encryptionMagic)]) != encryptionMagic {
+		return nil, errors.New("Not an encrypted dump")
+	}
+	return &decryptReader{r: r, aead: aead, prefix: header[len(encryptionMagic):]}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Each chunk's nonce is the random prefix, the chunk's number and whether it
+// is the last chunk, so chunks can't be reordered, dropped or appended.
+func chunkNonce(prefix []byte, n uint32, last bool) []byte {
+	nonce := make([]byte, 0, noncePrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, n)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// Writes sealed chunks, each as a last flag byte, its length and the sealed data.
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	n      uint32
+	buf    []byte
+	closed bool
+	err    error
+}
+
+// Returns a writer encrypting to w. Closing it writes the last chunk but does not close w.
+func newEncryptWriter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, encryptionMagic+string(prefix)); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, prefix: prefix, buf: make([]byte, 0, encryptionChunk)}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 && e.err == nil {
+		// Only seal a full chunk once more data follows, the last chunk is sealed by Close
+		if len(e.buf) == encryptionChunk {
+			e.err = e.seal(false)
+		}
+		n := copy(e.buf[len(e.buf):encryptionChunk], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, e.err
+}
+
+func (e *encryptWriter) Close() error {
+	if !e.closed {
+		e.closed = true
+		if e.err == nil {
+			e.err = e.seal(true)
+		}
+	}
+	return e.err
+}
+
+func (e *encryptWriter) seal(last bool) error {
+	sealed := e.aead.Seal(nil, chunkNonce(e.prefix, e.n, last), e.buf, nil)
+	var header [5]byte
+	if last {
+		header[0] = 1
+	}
+	binary.BigEndian.PutUint32(header[1:], uint32(len(sealed)))
+	if _, err := e.w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(sealed); err != nil {
+		return err
+	}
+	e.n++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+type decryptReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	n      uint32
+	buf    bytes.Reader
+	done   bool
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for d.buf.Len() == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	return d.buf.Read(p)
+}
+
+// Reads and opens the next chunk.
+func (d *decryptReader) open() error {
+	var header [5]byte
+	if _, err := io.ReadFull(d.r, header[:]); err != nil {
+		// The last chunk is always written, so running out before it means the dump was cut short
+		return unexpectedEOF(err)
+	}
+	last := header[0] == 1
+	size := binary.BigEndian.Uint32(header[1:])
+	if header[0] > 1 || size > encryptionChunk+uint32(d.aead.Overhead()) {
+		return errors.New("Invalid encrypted chunk")
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return unexpectedEOF(err)
+	}
+	plain, err := d.aead.Open(sealed[:0], chunkNonce(d.prefix, d.n, last), sealed, nil)
+	if err != nil {
+		return errors.New("Encrypted dump is corrupt or the key is wrong")
+	}
+	d.n++
+	d.done = last
+	d.buf.Reset(plain)
+	return nil
+}
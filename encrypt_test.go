@@ -0,0 +1,122 @@
+package mysqldump
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestEncryptDecrypt(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"short", 10},
+		{"one chunk", encryptionChunk},
+		{"several chunks", 3*encryptionChunk + 17},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plain := bytes.Repeat([]byte("0123456789"), test.size/10+1)[:test.size]
+			var sealed bytes.Buffer
+			w, err := newEncryptWriter(&sealed, testKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write(plain); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(sealed.Bytes(), []byte("0123456789")) && test.size > 0 {
+				t.Error("ciphertext holds the plaintext")
+			}
+
+			r, err := DecryptReader(bytes.NewReader(sealed.Bytes()), testKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Errorf("decrypted %d bytes, want %d", len(got), len(plain))
+			}
+		})
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	var sealed bytes.Buffer
+	w, err := newEncryptWriter(&sealed, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, strings.Repeat("x", 2*encryptionChunk))
+	w.Close()
+	data := sealed.Bytes()
+
+	tests := []struct {
+		name string
+		data []byte
+		key  []byte
+	}{
+		{"wrong key", data, []byte("fedcba9876543210fedcba9876543210")},
+		{"flipped byte", flip(data, len(data)/2), testKey},
+		{"truncated", data[:len(data)-encryptionChunk], testKey},
+		{"last chunk dropped", data[:len(encryptionMagic)+noncePrefixSize+5+encryptionChunk+16], testKey},
+		{"not encrypted", []byte("-- Go SQL Dump"), testKey},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := DecryptReader(bytes.NewReader(test.data), test.key)
+			if err == nil {
+				_, err = io.ReadAll(r)
+			}
+			if err == nil {
+				t.Error("read without error")
+			}
+		})
+	}
+}
+
+func flip(data []byte, i int) []byte {
+	flipped := append([]byte(nil), data...)
+	flipped[i] ^= 1
+	return flipped
+}
+
+func TestEncryptedDump(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	var b bytes.Buffer
+	d := newTestDumper(t, f, WithEncryption(testKey), WithCompression(gzip.BestSpeed))
+	if err := d.DumpTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := d.extension(), ".sql.gz.enc"; got != want {
+		t.Errorf("extension = %q, want %q", got, want)
+	}
+	r, err := DecryptReader(&b, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != wantDump {
+		t.Errorf("decrypted dump is:\n%s", got)
+	}
+}
@@ -0,0 +1,345 @@
+package mysqldump
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Result of a query to fakeDB.
+type fakeResult struct {
+	columns []string
+	types   []string // Database type name of each column
+	rows    [][]driver.Value
+	err     error
+}
+
+// A database/sql driver answering queries with canned results, for tests.
+//
+// Results are looked up by the query followed by its arguments, as
+// fmt.Sprint(query, args), then by the query alone, then by the longest key
+// ending in '*' that the query starts with, keeping as many rows as a trailing
+// LIMIT allows. Statements without a result succeed, queries without one fail.
+type fakeDB struct {
+	mu      sync.Mutex
+	results map[string]fakeResult
+	tables  [][]driver.Value // For SHOW FULL TABLES, see addTable
+	log     []string         // Queries and statements in the order they ran
+	conns   int              // Connections opened
+
+	// Answers queries before the results when set, such as to fail once
+	handle func(query string, args []driver.Value) (fakeResult, bool)
+}
+
+// Time of every dump in tests, see WithClock.
+var fakeNow = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// Returns a fake server running version 8.0.36 with the database 'db' selected.
+func newFakeDB() *fakeDB {
+	f := &fakeDB{results: make(map[string]fakeResult)}
+	f.set("SELECT version()", []string{"version()"}, fakeRow("8.0.36"))
+	f.set("SELECT DATABASE()", []string{"DATABASE()"}, fakeRow("db"))
+	f.set("SELECT @@SESSION.sql_mode", []string{"@@SESSION.sql_mode"}, fakeRow("STRICT_TRANS_TABLES"))
+	f.set("SHOW FULL TABLES FROM `db` WHERE Table_type = 'VIEW'", []string{"Tables_in_db", "Table_type"})
+	f.set("SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE *", []string{"COLUMN_NAME"})
+	f.set("SHOW CREATE DATABASE IF NOT EXISTS *", []string{"Database", "Create Database"},
+		fakeRow("db", "CREATE DATABASE /*!32312 IF NOT EXISTS*/ `db` /*!40100 DEFAULT CHARACTER SET utf8mb4 */"))
+	return f
+}
+
+func fakeRow(values ...driver.Value) []driver.Value {
+	return values
+}
+
+// Sets the result of query, see fakeDB.
+func (f *fakeDB) set(query string, columns []string, rows ...[]driver.Value) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[query] = fakeResult{columns: columns, rows: rows}
+}
+
+// Makes query fail with err.
+func (f *fakeDB) fail(query string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results[query] = fakeResult{err: err}
+}
+
+// Adds a table to the database 'db', with columns of the given types. Its
+// rows are the result of any SELECT of its columns, whatever the condition.
+func (f *fakeDB) addTable(name string, columns, types []string, rows ...[]driver.Value) {
+	definitions := make([]string, len(columns))
+	columnRows := make([][]driver.Value, len(columns))
+	for i, column := range columns {
+		definitions[i] = "  " + quoteIdentifier(column) + " " + strings.ToLower(types[i])
+		columnRows[i] = fakeRow(column, "")
+	}
+	create := "CREATE TABLE " + quoteIdentifier(name) + " (\n" + strings.Join(definitions, ",\n") +
+		"\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci"
+
+	f.mu.Lock()
+	f.tables = append(f.tables, fakeRow(name, "BASE TABLE"))
+	tables := f.tables
+	f.mu.Unlock()
+	f.set("SHOW FULL TABLES FROM `db` WHERE Table_type = 'BASE TABLE'", []string{"Tables_in_db", "Table_type"}, tables...)
+	f.set("SHOW CREATE TABLE "+qualify("db", name), []string{"Table", "Create Table"}, fakeRow(name, create))
+	f.set(fmt.Sprint("SELECT COLUMN_NAME, EXTRA FROM information_schema.COLUMNS "+
+		"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", []driver.Value{"db", name}),
+		[]string{"COLUMN_NAME", "EXTRA"}, columnRows...)
+	f.setRows(name, columns, types, rows...)
+}
+
+// Replaces the rows of a table added with addTable.
+func (f *fakeDB) setRows(name string, columns, types []string, rows ...[]driver.Value) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results["SELECT "+quoteList(columns)+" FROM "+qualify("db", name)+"*"] = fakeResult{columns: columns, types: types, rows: rows}
+}
+
+// Sets the primary key of a table added with addTable.
+func (f *fakeDB) setPrimaryKey(name string, columns ...string) {
+	rows := make([][]driver.Value, len(columns))
+	for i, column := range columns {
+		rows[i] = fakeRow(column)
+	}
+	f.set(fmt.Sprint("SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE "+
+		"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION",
+		[]driver.Value{"db", name}), []string{"COLUMN_NAME"}, rows...)
+}
+
+// Returns the queries and statements run so far.
+func (f *fakeDB) queries() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.log...)
+}
+
+// Reports whether a query or statement starting with prefix was run.
+func (f *fakeDB) ran(prefix string) bool {
+	for _, query := range f.queries() {
+		if strings.HasPrefix(query, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+var queryLimit = regexp.MustCompile(" LIMIT ([0-9]+)$")
+
+func (f *fakeDB) lookup(query string, args []driver.Value) (fakeResult, bool) {
+	f.mu.Lock()
+	f.log = append(f.log, query)
+	handle := f.handle
+	f.mu.Unlock()
+	if handle != nil {
+		if r, ok := handle(query, args); ok {
+			return r, true
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r, ok := f.results[fmt.Sprint(query, args)]; ok && len(args) > 0 {
+		return r, true
+	}
+	if r, ok := f.results[query]; ok {
+		return r, true
+	}
+	best := ""
+	for key := range f.results {
+		if strings.HasSuffix(key, "*") && strings.HasPrefix(query, key[:len(key)-1]) && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best == "" {
+		return fakeResult{}, false
+	}
+	// Honour the LIMIT of row queries
+	r := f.results[best]
+	if match := queryLimit.FindStringSubmatch(query); match != nil {
+		limit, _ := strconv.Atoi(match[1])
+		r.rows = r.rows[:min(limit, len(r.rows))]
+	}
+	return r, true
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.conns++
+	return &fakeConn{f}, nil
+}
+
+func (f *fakeDB) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fake: use sql.OpenDB")
+}
+
+type fakeConn struct {
+	f *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fake: statements are not prepared")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, named []driver.NamedValue) (driver.Result, error) {
+	if r, ok := c.f.lookup(query, values(named)); ok && r.err != nil {
+		return nil, r.err
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, named []driver.NamedValue) (driver.Rows, error) {
+	r, ok := c.f.lookup(query, values(named))
+	if !ok {
+		return nil, fmt.Errorf("fake: unexpected query %q", query)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &fakeRows{r: r}, nil
+}
+
+func values(named []driver.NamedValue) []driver.Value {
+	args := make([]driver.Value, len(named))
+	for i, arg := range named {
+		args[i] = arg.Value
+	}
+	return args
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeRows struct {
+	r fakeResult
+	n int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.r.columns
+}
+
+func (r *fakeRows) ColumnTypeDatabaseTypeName(i int) string {
+	if i < len(r.r.types) {
+		return r.r.types[i]
+	}
+	return "VARCHAR"
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.n >= len(r.r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.r.rows[r.n])
+	r.n++
+	return nil
+}
+
+// Adds the table 'users' of the fixtures used by most tests.
+func (f *fakeDB) addUsers() {
+	f.addTable("users", []string{"id", "name", "data"}, []string{"INT", "VARCHAR", "BLOB"},
+		fakeRow(int64(1), "O'Brien", []byte{0, 0xff}),
+		fakeRow(int64(2), nil, nil))
+}
+
+// Registers a Dumper for f with a fixed clock, in a temporary directory.
+func newTestDumper(t testing.TB, f *fakeDB, opts ...Option) *Dumper {
+	t.Helper()
+	opts = append([]Option{WithClock(func() time.Time { return fakeNow })}, opts...)
+	d, err := Register(sql.OpenDB(f), t.TempDir(), "dump", opts...)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return d
+}
+
+// Returns the dump of f with opts, as written by DumpTo.
+func dumpString(t testing.TB, f *fakeDB, opts ...Option) string {
+	t.Helper()
+	var b strings.Builder
+	if err := newTestDumper(t, f, opts...).DumpTo(&b); err != nil {
+		t.Fatalf("DumpTo: %v", err)
+	}
+	return b.String()
+}
+
+// A slog.Handler keeping every record, for tests of what is logged.
+type logRecorder struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *logRecorder) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *logRecorder) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r.Clone())
+	return nil
+}
+
+func (h *logRecorder) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+func (h *logRecorder) WithGroup(string) slog.Handler {
+	return h
+}
+
+// Returns the level and message of each record, with the attributes named
+// in keys, such as "INFO dumped table table=users".
+func (h *logRecorder) lines(keys ...string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	lines := make([]string, len(h.records))
+	for i, r := range h.records {
+		lines[i] = r.Level.String() + " " + r.Message
+		r.Attrs(func(a slog.Attr) bool {
+			for _, key := range keys {
+				if a.Key == key {
+					lines[i] += " " + a.String()
+				}
+			}
+			return true
+		})
+	}
+	return lines
+}
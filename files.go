@@ -0,0 +1,279 @@
+package mysqldump
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// Name of the file listing the files of a dump in restore order, see WithFilePerTable.
+const manifestName = "manifest.txt"
+
+// Name of the file describing each file of a compressed dump, see WithFilePerTable.
+const indexName = "index.json"
+
+// Writes each table to its own file instead of a single dump file. Dump
+// creates a directory named with the dump's format holding
+// '<db>.<table>.sql' for each table, '<format>.sql' with the views, routines
+// and events, and manifest.txt listing the files in the order to restore them.
+// Every file restores on its own. DumpTo is not affected.
+//
+// Each file is compressed on its own with WithCompressor, so single tables
+// can be restored without the rest. index.json then lists each file in
+// restore order with its table, size, uncompressed bytes, rows and checksum
+// (see WithChecksums).
+func WithFilePerTable(enabled bool) Option {
+	return func(d *Dumper) {
+		d.filePerTable = enabled
+	}
+}
+
+// Writes each table of a dump to its own file.
+type tableFiles struct {
+	d        *Dumper
+	run      *dumpRun
+	dir      string
+	done     map[[2]string]bool
+	database *table         // Database section of the schema being dumped, if any
+	files    []manifestFile // Files written so far, in restore order
+	archive  *tar.Writer    // Set to move finished files into, see WithArchive
+}
+
+// Describes the files of a dump in index.json, or manifest.json in an archive.
+type fileIndex struct {
+	Files []manifestFile `json:"files"` // In restore order
+}
+
+// A file of the dump, see tableFiles.
+type manifestFile struct {
+	Name     string `json:"name"`
+	Database string `json:"database,omitempty"` // Empty for the main file
+	Table    string `json:"table,omitempty"`
+	Size     int64  `json:"size"`  // Bytes in the file
+	Bytes    int64  `json:"bytes"` // Bytes before compression and encryption
+	Rows     int64  `json:"rows"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// Same as DumpContext but with one file per table, see WithFilePerTable.
+// The files are written to a '.partial' directory which is renamed once
+// complete, or removed if the dump fails. With WithArchive the directory
+// only holds each file until it is finished and moved into the archive.
+func (d *Dumper) dumpFiles(ctx context.Context, name string, run *dumpRun) (err error) {
+	// Continue a dump that failed, see WithResume
+	var p, partial string
+	var resumed []manifestFile
+	if d.resume {
+		var found string
+		if found, resumed, err = d.findCheckpoint(); err != nil {
+			return err
+		}
+		if found != "" {
+			name, p = found, path.Join(d.dir, found)
+			partial = p + ".partial"
+		}
+	}
+
+	// Create partial dump directory, exclusively so concurrent dumps can't share it
+	if partial == "" {
+		err = d.claimName(name, func(candidate string) error {
+			name, p = candidate, path.Join(d.dir, candidate)
+			if d.archive {
+				p += ".tar"
+			}
+			if e, _ := exists(p); e {
+				return fs.ErrExist
+			}
+			partial = p + ".partial"
+			return os.Mkdir(partial, d.dirMode())
+		})
+		if err != nil {
+			return err
+		}
+	}
+	// The archive is written inside the partial directory until it is complete
+	done := partial
+	if d.archive {
+		done = path.Join(partial, name+".tar")
+	}
+	checkpoint := path.Join(partial, checkpointName)
+	defer func() {
+		r := recover()
+		if err == nil && r == nil {
+			if err = os.Remove(checkpoint); errors.Is(err, fs.ErrNotExist) {
+				err = nil
+			}
+			if err == nil {
+				err = renameNew(done, p)
+			}
+		}
+		// A failed dump is kept to be continued once it has completed a table
+		if kept, _ := exists(checkpoint); d.archive || (err != nil || r != nil) && !(d.resume && kept) {
+			os.RemoveAll(partial)
+		}
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	// Everything but the tables goes to the main file, restored last as views
+	// depend on the tables. Formats other than SQL have nothing but the tables.
+	run.files = &tableFiles{d: d, dir: partial, run: run}
+	run.files.resume(resumed)
+	if d.archive {
+		var out *os.File
+		if out, err = d.createFile(done); err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := out.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		run.files.archive = tar.NewWriter(out)
+	}
+	if d.rowsOnly() {
+		if err = d.dumpTo(ctx, io.Discard, run); err != nil {
+			return err
+		}
+	} else {
+		main := manifestFile{Name: name + d.extension()}
+		f, err := d.createFile(path.Join(partial, main.Name))
+		if err != nil {
+			return err
+		}
+		w, err := d.encodeWriter(run.count(&countingWriter{f, &main.Size}))
+		if err == nil {
+			err = d.dumpTo(ctx, &countingWriter{w, &main.Bytes}, run)
+			if cerr := w.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		if err = run.files.add(main); err != nil {
+			return err
+		}
+	}
+
+	if d.archive {
+		return run.files.closeArchive()
+	}
+	if d.compressor != nil {
+		index, err := run.files.index()
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(path.Join(partial, indexName), index, d.fileMode); err != nil {
+			return err
+		}
+	}
+	names := make([]string, len(run.files.files))
+	for i, file := range run.files.files {
+		names[i] = file.Name
+	}
+	manifest := strings.Join(names, "\n") + "\n"
+	return os.WriteFile(path.Join(partial, manifestName), []byte(manifest), d.fileMode)
+}
+
+// Renders the files written so far as JSON, with the rows and checksum of each table.
+func (f *tableFiles) index() ([]byte, error) {
+	tables := make(map[[2]string]TableStats)
+	for _, stats := range f.run.stats.Tables {
+		tables[[2]string{stats.Database, stats.Table}] = stats
+	}
+	index := fileIndex{Files: f.files}
+	for i, file := range index.Files {
+		stats := tables[[2]string{file.Database, file.Table}]
+		index.Files[i].Rows, index.Files[i].Checksum = stats.Rows, stats.Checksum
+	}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Records a finished file, moving it into the archive if there is one.
+func (f *tableFiles) add(file manifestFile) error {
+	if f.archive != nil {
+		if err := f.moveToArchive(file.Name); err != nil {
+			return err
+		}
+	}
+	f.files = append(f.files, file)
+	return nil
+}
+
+// Writes the table rendered by render to '<db>.<table>.sql', between the dump
+// header and footer.
+func (f *tableFiles) write(schema, name string, render func(io.Writer) error) error {
+	file := manifestFile{Name: escapeName(schema) + "." + escapeName(name) + f.d.extension(), Database: schema, Table: name}
+	if err := f.writeFile(&file, render); err != nil {
+		return err
+	}
+	return f.add(file)
+}
+
+// Escapes a database or table name for file names and checksum lines. Dots
+// are escaped too, so the one joining the database and table is unambiguous.
+func escapeName(name string) string {
+	return strings.ReplaceAll(url.PathEscape(name), ".", "%2E")
+}
+
+// Writes file in the directory, between the dump header and footer, and counts its bytes.
+func (f *tableFiles) writeFile(file *manifestFile, render func(io.Writer) error) (err error) {
+	out, err := f.d.createFile(path.Join(f.dir, file.Name))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		// Leave no file behind for a table skipped by WithContinueOnError
+		if err != nil {
+			os.Remove(path.Join(f.dir, file.Name))
+		}
+	}()
+
+	ew, err := f.d.encodeWriter(f.run.count(&countingWriter{out, &file.Size}))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := ew.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	w := &countingWriter{ew, &file.Bytes}
+
+	if err = f.d.execute(w, "header", f.run.data); err != nil {
+		return err
+	}
+	if f.database != nil {
+		if err = f.d.execute(w, "database", f.database); err != nil {
+			return err
+		}
+	}
+	if err = render(w); err != nil {
+		return err
+	}
+	data := f.run.data
+	data.CompleteTime = f.d.completeTime()
+	if err = f.d.execute(w, "footer", data); err != nil {
+		return err
+	}
+	return nil
+}
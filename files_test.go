@@ -0,0 +1,178 @@
+package mysqldump
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestFilePerTable(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	f.addTable("other", []string{"id"}, []string{"INT"}, fakeRow(int64(1)))
+	d := newTestDumper(t, f, WithFilePerTable(true))
+	if err := d.Dump(); err != nil {
+		t.Fatal(err)
+	}
+	dir := path.Join(d.dir, "dump")
+	manifest, err := os.ReadFile(path.Join(dir, manifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(manifest), "db.users.sql\ndb.other.sql\ndump.sql\n"; got != want {
+		t.Errorf("manifest is %q, want %q", got, want)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("directory mode = %v, %v, want 0700", info.Mode().Perm(), err)
+	}
+
+	users, err := os.ReadFile(path.Join(dir, "db.users.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"SET NAMES utf8mb4;", "CREATE TABLE `users`", "INSERT INTO `users` VALUES", "-- Dump completed on"} {
+		if !strings.Contains(string(users), want) {
+			t.Errorf("table file doesn't contain %q:\n%s", want, users)
+		}
+	}
+	if strings.Contains(string(users), "`other`") {
+		t.Error("table file holds another table")
+	}
+	main, err := os.ReadFile(path.Join(dir, "dump.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(main), "CREATE TABLE") {
+		t.Errorf("main file holds tables:\n%s", main)
+	}
+	if e, _ := exists(dir + ".partial"); e {
+		t.Error("partial directory left behind")
+	}
+}
+
+func TestFilePerTableIndex(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	d := newTestDumper(t, f, WithFilePerTable(true), WithCompression(gzip.BestSpeed), WithChecksums(true))
+	if err := d.Dump(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path.Join(d.dir, "dump", indexName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index fileIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Files) != 2 {
+		t.Fatalf("index lists %d files", len(index.Files))
+	}
+	users := index.Files[0]
+	if users.Name != "db.users.sql.gz" || users.Database != "db" || users.Table != "users" || users.Rows != 2 || users.Checksum == "" {
+		t.Errorf("index lists %+v", users)
+	}
+	info, err := os.Stat(path.Join(d.dir, "dump", users.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users.Size != info.Size() || users.Bytes <= users.Size/2 {
+		t.Errorf("index has size %d and bytes %d, file is %d", users.Size, users.Bytes, info.Size())
+	}
+}
+
+func TestFilePerTableFormats(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	d := newTestDumper(t, f, WithFilePerTable(true), WithOutputFormat(FormatCSV))
+	if err := d.Dump(); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := os.ReadFile(path.Join(d.dir, "dump", manifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(manifest), "db.users.csv\n"; got != want {
+		t.Errorf("manifest is %q, want %q", got, want)
+	}
+}
+
+func TestFilePerTableFailure(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	f.addTable("other", []string{"id"}, []string{"INT"})
+	f.fail("SHOW CREATE TABLE `db`.`other`", os.ErrPermission)
+	d := newTestDumper(t, f, WithFilePerTable(true))
+	if err := d.Dump(); err == nil {
+		t.Fatal("Dump succeeded")
+	}
+	if entries, _ := os.ReadDir(d.dir); len(entries) > 0 {
+		t.Errorf("failed dump left %s behind", entries[0].Name())
+	}
+}
+
+func TestEscapeName(t *testing.T) {
+	tests := map[string]string{
+		"users": "users",
+		"a.b":   "a%2Eb",
+		"my db": "my%20db",
+		"a/b%c": "a%2Fb%25c",
+		"..":    "%2E%2E",
+	}
+	for name, want := range tests {
+		if got := escapeName(name); got != want {
+			t.Errorf("escapeName(%q) = %q, want %q", name, got, want)
+		}
+	}
+	if a, b := escapeName("a.b")+"."+escapeName("c"), escapeName("a")+"."+escapeName("b.c"); a == b {
+		t.Errorf("database a.b table c and database a table b.c both escape to %q", a)
+	}
+}
+
+func TestFileMode(t *testing.T) {
+	// The mode is applied before the umask, find out what it takes away
+	probe := path.Join(t.TempDir(), "probe")
+	if err := os.WriteFile(probe, nil, 0777); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(probe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	umask := 0777 &^ info.Mode().Perm()
+	if umask&0750 != 0 {
+		t.Skipf("umask %v clears bits of 0750", umask)
+	}
+
+	f := newFakeDB()
+	f.addUsers()
+	tests := []struct {
+		name  string
+		opts  []Option
+		files []string
+	}{
+		{"single file", nil, []string{"dump.sql"}},
+		{"file per table", []Option{WithFilePerTable(true)}, []string{"dump/dump.sql", "dump/db.users.sql", "dump/" + manifestName}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := newTestDumper(t, f, append(test.opts, WithFileMode(0640))...)
+			if err := d.Dump(); err != nil {
+				t.Fatal(err)
+			}
+			for _, file := range test.files {
+				if info, err := os.Stat(path.Join(d.dir, file)); err != nil || info.Mode().Perm() != 0640 {
+					t.Errorf("%s mode = %v, %v, want 0640", file, info.Mode().Perm(), err)
+				}
+			}
+			if d.filePerTable {
+				if info, err := os.Stat(path.Join(d.dir, "dump")); err != nil || info.Mode().Perm() != 0750 {
+					t.Errorf("directory mode = %v, %v, want 0750", info.Mode().Perm(), err)
+				}
+			}
+		})
+	}
+}
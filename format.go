@@ -0,0 +1,279 @@
+package mysqldump
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"unicode/utf8"
+)
+
+// Format the dump is written in, see WithOutputFormat.
+type OutputFormat int
+
+const (
+	FormatSQL  OutputFormat = iota // SQL statements restoring the databases
+	FormatJSON                     // A JSON object per row, one per line
+	FormatCSV                      // RFC 4180 CSV with a header line per table
+)
+
+func (f OutputFormat) extension() string {
+	switch f {
+	case FormatJSON:
+		return ".jsonl"
+	case FormatCSV:
+		return ".csv"
+	}
+	return ".sql"
+}
+
+// Sets the format of the dump. Defaults to FormatSQL. FormatJSON and
+// FormatCSV only write the rows of each table, for loading into other
+// systems, without the header, structure, views, routines or events. Binary
+// values are base64 encoded, and NULL is null in JSON and an empty unquoted
+// field in CSV. JSON columns are nested as documents in JSON rather than
+// strings. A JSON dump of several tables holds them one after the other, use
+// WithFilePerTable or DumpTable for a file per table. Register fails for
+// FormatCSV without WithFilePerTable, as each table's header line would run
+// into the rows of the one before, but DumpTable still writes its table to w.
+// Register also fails with WithChecksums, as there is no footer to hold them.
+func WithOutputFormat(format OutputFormat) Option {
+	return func(d *Dumper) {
+		d.outputFormat = format
+	}
+}
+
+// Reports whether only the rows are dumped, without any SQL around them.
+func (d *Dumper) rowsOnly() bool {
+	return d.outputFormat != FormatSQL
+}
+
+// Renders the rows of a table in the output format.
+type rowEncoder interface {
+	appendHeader(b []byte) []byte // Before the first row
+	appendRow(b []byte, data []sql.RawBytes) []byte
+	appendFooter(b []byte) []byte // After the last row
+}
+
+func (t *table) newEncoder() rowEncoder {
+	switch t.d.outputFormat {
+	case FormatJSON:
+		return newJSONEncoder(t)
+	case FormatCSV:
+		return &csvEncoder{t: t}
+	}
+	return &sqlEncoder{t: t, insert: t.insertPrefix(), end: t.insertSuffix() + ";"}
+}
+
+// Writes the rows of a table without the table template, for formats other than SQL.
+func (t *table) writeRows(w io.Writer) (err error) {
+	if t.rows != nil {
+		defer func() { t.rows.Close() }()
+	}
+	for chunk := range t.Stream() {
+		// Keep draining the stream so it finishes
+		if err == nil {
+			_, err = io.WriteString(w, chunk)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return t.err
+}
+
+// Starts a new INSERT for every row, or with extended inserts whenever the
+// next row would push the statement over the max packet size or the
+// statement has the most rows allowed by WithInsertBatchRows. Rows are never
+// split.
+type sqlEncoder struct {
+	t      *table
+	insert string // Start of each INSERT
+	end    string // End of each INSERT
+	row    []byte
+	size   int // Length of the current INSERT
+	rows   int // Rows in the current INSERT
+}
+
+func (e *sqlEncoder) appendHeader(b []byte) []byte {
+	return b
+}
+
+func (e *sqlEncoder) appendRow(b []byte, data []sql.RawBytes) []byte {
+	e.row = e.t.appendValues(e.row[:0], data)
+	switch {
+	case e.size == 0:
+		b = append(append(b, e.insert...), e.row...)
+		e.size = len(e.insert) + len(e.row)
+		e.rows = 1
+	case !e.t.d.extendedInsert || e.size+len(e.row)+1+len(e.end) > e.t.d.maxPacket ||
+		e.t.d.insertBatchRows > 0 && e.rows >= e.t.d.insertBatchRows:
+		b = append(append(append(append(b, e.end...), '\n'), e.insert...), e.row...)
+		e.size = len(e.insert) + len(e.row)
+		e.rows = 1
+	default:
+		b = append(append(b, ','), e.row...)
+		e.size += 1 + len(e.row)
+		e.rows++
+	}
+	return b
+}
+
+func (e *sqlEncoder) appendFooter(b []byte) []byte {
+	if e.size == 0 {
+		return b
+	}
+	return append(b, e.end...)
+}
+
+// Writes each row as a JSON object keyed by column name.
+type jsonEncoder struct {
+	t    *table
+	keys [][]byte // Quoted column names followed by ':'
+}
+
+func newJSONEncoder(t *table) *jsonEncoder {
+	keys := make([][]byte, len(t.columns))
+	for i, column := range t.columns {
+		keys[i] = append(appendJSONString(nil, []byte(column)), ':')
+	}
+	return &jsonEncoder{t: t, keys: keys}
+}
+
+func (e *jsonEncoder) appendHeader(b []byte) []byte {
+	return b
+}
+
+func (e *jsonEncoder) appendRow(b []byte, data []sql.RawBytes) []byte {
+	b = append(b, '{')
+	for i, _ := range data {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, e.keys[i]...)
+		value, kind := e.t.value(i, data[i])
+		switch {
+		case value == nil:
+			b = append(b, "null"...)
+		case kind == binaryValue || kind == bitValue:
+			b = append(base64.StdEncoding.AppendEncode(append(b, '"'), value), '"')
+		case kind == numericValue && isJSONNumber(value):
+			b = append(b, value...)
+		case kind == jsonValue:
+			b = appendCompactJSON(b, value)
+		default:
+			b = appendJSONString(b, value)
+		}
+	}
+	return append(b, '}', '\n')
+}
+
+func (e *jsonEncoder) appendFooter(b []byte) []byte {
+	return b
+}
+
+// Appends a JSON document on a single line, as rows are one per line, or
+// as a string if it isn't valid JSON.
+func appendCompactJSON(b, value []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, value); err != nil {
+		return appendJSONString(b, value)
+	}
+	return append(b, buf.Bytes()...)
+}
+
+// Reports whether a numeric value can be written as a JSON number as is.
+func isJSONNumber(value []byte) bool {
+	return len(value) > 0 && (value[0] == '-' || value[0] >= '0' && value[0] <= '9') && json.Valid(value)
+}
+
+// Appends value as a quoted JSON string, replacing invalid UTF-8.
+func appendJSONString(b, value []byte) []byte {
+	const hexDigits = "0123456789abcdef"
+	b = append(b, '"')
+	for len(value) > 0 {
+		r, size := utf8.DecodeRune(value)
+		switch {
+		case r == '"' || r == '\\':
+			b = append(b, '\\', byte(r))
+		case r == '\n':
+			b = append(b, `\n`...)
+		case r == '\r':
+			b = append(b, `\r`...)
+		case r == '\t':
+			b = append(b, `\t`...)
+		case r < 0x20:
+			b = append(b, '\\', 'u', '0', '0', hexDigits[r>>4], hexDigits[r&0xf])
+		case r == utf8.RuneError && size == 1:
+			b = append(b, "\ufffd"...)
+		default:
+			b = append(b, value[:size]...)
+		}
+		value = value[size:]
+	}
+	return append(b, '"')
+}
+
+// Writes the column names and then each row as RFC 4180 CSV records.
+type csvEncoder struct {
+	t *table
+}
+
+func (e *csvEncoder) appendHeader(b []byte) []byte {
+	if len(e.t.columns) == 0 {
+		return b
+	}
+	for i, column := range e.t.columns {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendCSVField(b, []byte(column))
+	}
+	return append(b, '\r', '\n')
+}
+
+func (e *csvEncoder) appendRow(b []byte, data []sql.RawBytes) []byte {
+	for i, _ := range data {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		value, kind := e.t.value(i, data[i])
+		switch {
+		case value == nil:
+			// NULL is the only empty field that isn't quoted
+		case (kind == binaryValue || kind == bitValue) && len(value) > 0:
+			b = base64.StdEncoding.AppendEncode(b, value)
+		default:
+			b = appendCSVField(b, value)
+		}
+	}
+	return append(b, '\r', '\n')
+}
+
+func (e *csvEncoder) appendFooter(b []byte) []byte {
+	return b
+}
+
+// Appends a CSV field, quoted if it is empty or holds a comma, quote or line break.
+func appendCSVField(b, value []byte) []byte {
+	quote := len(value) == 0
+	for _, c := range value {
+		if c == ',' || c == '"' || c == '\r' || c == '\n' {
+			quote = true
+			break
+		}
+	}
+	if !quote {
+		return append(b, value...)
+	}
+
+	b = append(b, '"')
+	for _, c := range value {
+		if c == '"' {
+			b = append(b, '"')
+		}
+		b = append(b, c)
+	}
+	return append(b, '"')
+}
@@ -0,0 +1,113 @@
+package mysqldump
+
+import (
+	"testing"
+)
+
+func TestOutputFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		format OutputFormat
+		opts   []Option
+		want   string
+	}{
+		{
+			name:   "sql",
+			format: FormatSQL,
+			want:   wantDump,
+		},
+		{
+			name:   "json",
+			format: FormatJSON,
+			want: `{"id":1,"name":"O'Brien","data":"AP8=","doc":{"a":[1,2]}}` + "\n" +
+				`{"id":2,"name":null,"data":null,"doc":"not json"}` + "\n",
+		},
+		{
+			name:   "csv",
+			format: FormatCSV,
+			opts:   []Option{WithFilePerTable(true)},
+			want: "id,name,data,doc\r\n" +
+				"1,O'Brien,AP8=,\"{\"\"a\"\": [1, 2]}\"\r\n" +
+				"2,,,not json\r\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeDB()
+			if test.format == FormatSQL {
+				f.addUsers()
+			} else {
+				f.addTable("users", []string{"id", "name", "data", "doc"}, []string{"INT", "VARCHAR", "BLOB", "JSON"},
+					fakeRow(int64(1), "O'Brien", []byte{0, 0xff}, `{"a": [1, 2]}`),
+					fakeRow(int64(2), nil, nil, "not json"))
+			}
+			if got := dumpString(t, f, append(test.opts, WithOutputFormat(test.format))...); got != test.want {
+				t.Errorf("dump is:\n%q\nwant:\n%q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestOutputFormatExtension(t *testing.T) {
+	for format, want := range map[OutputFormat]string{FormatSQL: ".sql", FormatJSON: ".jsonl", FormatCSV: ".csv"} {
+		if got := format.extension(); got != want {
+			t.Errorf("extension of %d = %q, want %q", format, got, want)
+		}
+	}
+}
+
+func TestAppendJSONString(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", `""`},
+		{"plain", `"plain"`},
+		{`"quoted" \`, `"\"quoted\" \\"`},
+		{"a\nb\tc\r", `"a\nb\tc\r"`},
+		{"\x01\x1f", `"\u0001\u001f"`},
+		{"ünï", `"ünï"`},
+		{"bad\xffutf8", "\"bad�utf8\""},
+	}
+	for _, test := range tests {
+		if got := string(appendJSONString(nil, []byte(test.value))); got != test.want {
+			t.Errorf("appendJSONString(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
+
+func TestIsJSONNumber(t *testing.T) {
+	for value, want := range map[string]bool{
+		"1":        true,
+		"-1.5":     true,
+		"1e10":     true,
+		"":         false,
+		"0x1f":     false,
+		"NaN":      false,
+		"+1":       false,
+		"00012.50": false,
+	} {
+		if got := isJSONNumber([]byte(value)); got != want {
+			t.Errorf("isJSONNumber(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestAppendCSVField(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", `""`},
+		{"plain", "plain"},
+		{"a,b", `"a,b"`},
+		{`say "hi"`, `"say ""hi"""`},
+		{"two\nlines", "\"two\nlines\""},
+		{"cr\r", "\"cr\r\""},
+	}
+	for _, test := range tests {
+		if got := string(appendCSVField(nil, []byte(test.value))); got != test.want {
+			t.Errorf("appendCSVField(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
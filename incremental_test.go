@@ -0,0 +1,104 @@
+package mysqldump
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIncrementalColumn(t *testing.T) {
+	since := time.Date(2024, 1, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	tests := []struct {
+		name  string
+		setup func(f *fakeDB)
+		opts  []Option
+		want  string // Query reading the rows, or empty if none
+		args  []driver.Value
+	}{
+		{
+			name: "without since",
+			opts: []Option{WithIncrementalColumn("users", "name")},
+			want: "SELECT `id`, `name`, `data` FROM `db`.`users`",
+		},
+		{
+			name: "since",
+			opts: []Option{WithIncrementalColumn("use*", "name"), WithSince(since)},
+			want: "SELECT `id`, `name`, `data` FROM `db`.`users` WHERE `name` >= ?",
+			args: []driver.Value{"2024-01-01 11:00:00"},
+		},
+		{
+			name: "since with where",
+			opts: []Option{WithIncrementalColumn("users", "name"), WithSince(since), WithWhere("users", "id = 1 OR id = 2")},
+			want: "SELECT `id`, `name`, `data` FROM `db`.`users` WHERE (id = 1 OR id = 2) AND `name` >= ?",
+			args: []driver.Value{"2024-01-01 11:00:00"},
+		},
+		{
+			name: "missing column",
+			opts: []Option{WithIncrementalColumn("users", "updated_at"), WithSince(since)},
+			want: "SELECT `id`, `name`, `data` FROM `db`.`users`",
+		},
+		{
+			name:  "excluded column",
+			setup: setUsersWithoutName,
+			opts:  []Option{WithIncrementalColumn("users", "name"), WithSince(since), WithExcludeColumns("users", "name")},
+			want:  "SELECT `id`, `data` FROM `db`.`users` WHERE `name` >= ?",
+			args:  []driver.Value{"2024-01-01 11:00:00"},
+		},
+		{
+			name:  "excluded column skips nothing",
+			setup: setUsersWithoutName,
+			opts: []Option{WithIncrementalColumn("users", "name"), WithSince(since), WithExcludeColumns("users", "name"),
+				WithSkipNonIncremental(true)},
+			want: "SELECT `id`, `data` FROM `db`.`users` WHERE `name` >= ?",
+			args: []driver.Value{"2024-01-01 11:00:00"},
+		},
+		{
+			name: "skip non incremental",
+			opts: []Option{WithIncrementalColumn("other", "name"), WithSince(since), WithSkipNonIncremental(true)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeDB()
+			f.addUsers()
+			if test.setup != nil {
+				test.setup(f)
+			}
+			var args []driver.Value
+			f.handle = func(query string, a []driver.Value) (fakeResult, bool) {
+				if strings.HasPrefix(query, "SELECT `id`") {
+					args = a
+				}
+				return fakeResult{}, false
+			}
+			got := dumpString(t, f, test.opts...)
+			var selects []string
+			for _, query := range f.queries() {
+				if strings.HasPrefix(query, "SELECT `id`") {
+					selects = append(selects, query)
+				}
+			}
+			if test.want == "" {
+				if len(selects) > 0 || strings.Contains(got, "INSERT") {
+					t.Errorf("rows of a table without the column were read with %q", selects)
+				}
+				if !strings.Contains(got, "CREATE TABLE `users`") {
+					t.Error("structure of a table without the column is missing")
+				}
+				return
+			}
+			if len(selects) != 1 || selects[0] != test.want {
+				t.Errorf("read rows with %q, want %q", selects, test.want)
+			}
+			if len(args) != len(test.args) || len(args) > 0 && args[0] != test.args[0] {
+				t.Errorf("read rows with arguments %q, want %q", args, test.args)
+			}
+		})
+	}
+}
+
+// Sets the rows of 'users' read when the column 'name' is excluded.
+func setUsersWithoutName(f *fakeDB) {
+	f.setRows("users", []string{"id", "data"}, []string{"INT", "BLOB"}, fakeRow(int64(1), nil))
+}
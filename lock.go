@@ -0,0 +1,81 @@
+package mysqldump
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// Matches access denied errors (1044, 1227) as reported by the
+// go-sql-driver/mysql ("Error 1227") and mymysql ("#1227") drivers.
+var accessDeniedError = regexp.MustCompile(`(?:Error |#)(?:1044|1227)\b`)
+
+// Matches the privileges and database of a SHOW GRANTS line for a database or all of them.
+var grantLine = regexp.MustCompile("^GRANT (.+) ON (\\*|`(?:[^`]|``)*`)\\.\\* TO ")
+
+// Dumps without locks instead of failing when the dump's user may not take
+// them. If FLUSH TABLES WITH READ LOCK is denied, see WithFlushLock, tables
+// are read without the global lock. If the user lacks the LOCK TABLES
+// privilege on a database, its tables are dumped without LOCK TABLES. Both
+// are logged as warnings. WithMasterData still fails without the global lock.
+func WithSkipLockTablesOnError(enabled bool) Option {
+	return func(d *Dumper) {
+		d.skipLockErrors = enabled
+	}
+}
+
+// Takes the global read lock, reporting whether it is held. Fails unless the
+// lock is denied and WithSkipLockTablesOnError allows dumping without it.
+func (d *Dumper) flushTables(ctx context.Context, conn Querier) (bool, error) {
+	_, err := conn.ExecContext(ctx, "FLUSH TABLES WITH READ LOCK")
+	if err != nil && d.skipLockErrors && !d.masterData && accessDeniedError.MatchString(err.Error()) {
+		d.logger.Warn("dumping without a global read lock", "error", err)
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Reports whether the dump's user has the LOCK TABLES privilege on schema,
+// as listed by SHOW GRANTS. Privileges granted through roles aren't seen.
+func hasLockTables(ctx context.Context, db Querier, schema string) (bool, error) {
+	rows, err := db.QueryContext(ctx, "SHOW GRANTS")
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var grant string
+		if err := rows.Scan(&grant); err != nil {
+			return false, err
+		}
+		match := grantLine.FindStringSubmatch(grant)
+		if match == nil {
+			continue
+		}
+		if match[2] != "*" && match[2] != quoteIdentifier(schema) {
+			continue
+		}
+		if strings.Contains(match[1], "ALL PRIVILEGES") || strings.Contains(match[1], "LOCK TABLES") {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// Reports whether the tables of schema are dumped without LOCK TABLES as the
+// user lacks the privilege, see WithSkipLockTablesOnError.
+func (d *Dumper) skipLockTables(ctx context.Context, db Querier, schema string) bool {
+	if !d.lockTables || !d.skipLockErrors || d.rowsOnly() {
+		return false
+	}
+	allowed, err := hasLockTables(ctx, db, schema)
+	if err != nil {
+		d.logger.Warn("checking LOCK TABLES privilege failed", "database", schema, "error", err)
+		return false
+	}
+	if !allowed {
+		d.logger.Warn("dumping without LOCK TABLES", "database", schema)
+	}
+	return !allowed
+}
@@ -0,0 +1,110 @@
+package mysqldump
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Returns the position of the first query or statement starting with each
+// prefix in what f ran, or -1.
+func queryOrder(f *fakeDB, prefixes ...string) []int {
+	queries := f.queries()
+	order := make([]int, len(prefixes))
+	for i, prefix := range prefixes {
+		order[i] = -1
+		for j, query := range queries {
+			if strings.HasPrefix(query, prefix) {
+				order[i] = j
+				break
+			}
+		}
+	}
+	return order
+}
+
+func TestFlushLock(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []string // In the order they run
+	}{
+		{"lock", []Option{WithFlushLock(true)},
+			[]string{"FLUSH TABLES WITH READ LOCK", "SELECT `id`", "UNLOCK TABLES"}},
+		{"snapshot", []Option{WithFlushLock(true), WithConsistentSnapshot(true)},
+			[]string{"FLUSH TABLES WITH READ LOCK", "START TRANSACTION WITH CONSISTENT SNAPSHOT", "UNLOCK TABLES", "SELECT `id`", "COMMIT"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeDB()
+			f.addUsers()
+			dumpString(t, f, test.opts...)
+			order := queryOrder(f, test.want...)
+			for i, n := range order {
+				if n < 0 || i > 0 && n < order[i-1] {
+					t.Fatalf("%q not run after %q, ran %q", test.want[i], test.want[max(i-1, 0)], f.queries())
+				}
+			}
+			// One connection both takes and releases the lock
+			if f.conns != 1 {
+				t.Errorf("dump opened %d connections, want 1", f.conns)
+			}
+		})
+	}
+}
+
+func TestSkipLockTablesOnError(t *testing.T) {
+	denied := errors.New("Error 1227 (42000): Access denied; you need (at least one of) the RELOAD privilege(s) for this operation")
+	tests := []struct {
+		name  string
+		setup func(f *fakeDB)
+		opts  []Option
+		not   string // Left out of the dump
+		warn  string
+	}{
+		{
+			name:  "global read lock",
+			setup: func(f *fakeDB) { f.fail("FLUSH TABLES WITH READ LOCK", denied) },
+			opts:  []Option{WithFlushLock(true)},
+			warn:  "WARN dumping without a global read lock",
+		},
+		{
+			name: "lock tables",
+			setup: func(f *fakeDB) {
+				f.set("SHOW GRANTS", []string{"Grants"}, fakeRow("GRANT SELECT, SHOW VIEW ON `db`.* TO `dump`@`%`"))
+			},
+			not:  "LOCK TABLES `users` WRITE;",
+			warn: "WARN dumping without LOCK TABLES",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeDB()
+			f.addUsers()
+			test.setup(f)
+			logs := &logRecorder{}
+			opts := append([]Option{WithSkipLockTablesOnError(true), WithLogger(slog.New(logs))}, test.opts...)
+			got := dumpString(t, f, opts...)
+			if !strings.Contains(got, "INSERT INTO `users` VALUES") {
+				t.Errorf("dump without locks has no rows:\n%s", got)
+			}
+			if test.not != "" && strings.Contains(got, test.not) {
+				t.Errorf("dump has %q:\n%s", test.not, got)
+			}
+			if lines := logs.lines(); !slices.Contains(lines, test.warn) {
+				t.Errorf("logged %q, want %q", lines, test.warn)
+			}
+		})
+	}
+
+	// The lock is still needed without the option
+	f := newFakeDB()
+	f.addUsers()
+	f.fail("FLUSH TABLES WITH READ LOCK", denied)
+	var b strings.Builder
+	if err := newTestDumper(t, f, WithFlushLock(true)).DumpTo(&b); !errors.Is(err, denied) {
+		t.Errorf("DumpTo = %v, want %v", err, denied)
+	}
+}
@@ -0,0 +1,38 @@
+package mysqldump
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Table options only MariaDB has, quoted when they are engine defined.
+var mariaDBTableOptions = regexp.MustCompile(" (?:`?(?:PAGE_COMPRESSED|PAGE_COMPRESSION_LEVEL|ENCRYPTED|ENCRYPTION_KEY_ID|IETF_QUOTES)`?|PAGE_CHECKSUM|TRANSACTIONAL)=(?:'[^']*'|[0-9A-Za-z_]+)")
+
+// Collations MariaDB has since 10.10, which MySQL doesn't know, as a column
+// attribute or table option.
+var mariaDBCollation = regexp.MustCompile(" COLLATE[= ][0-9a-z]+_uca1400_[0-9a-z_]+")
+
+// Removes the table options and collations of CREATE TABLE statements
+// that only MariaDB supports when dumping from MariaDB, so the dump loads
+// into MySQL too. The tables then get MySQL's defaults for them, such as
+// the character set's default collation.
+func WithPortableDDL(enabled bool) Option {
+	return func(d *Dumper) {
+		d.portableDDL = enabled
+	}
+}
+
+// Reports whether the version from version() is of a MariaDB server, such as '10.11.6-MariaDB-log'.
+func isMariaDB(server_version string) bool {
+	return strings.Contains(strings.ToLower(server_version), "mariadb")
+}
+
+// Removes MariaDB only clauses from a CREATE TABLE statement. The table's
+// COMMENT is left as is.
+func portableCreate(table_sql string) string {
+	before, options, after := splitTableOptions(table_sql)
+	before = mariaDBCollation.ReplaceAllString(before, "")
+	options = mariaDBCollation.ReplaceAllString(options, "")
+	options = mariaDBTableOptions.ReplaceAllString(options, "")
+	return before + options + after
+}
@@ -0,0 +1,37 @@
+package mysqldump
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPortableDDL(t *testing.T) {
+	create := "CREATE TABLE `users` (\n" +
+		"  `id` int NOT NULL,\n" +
+		"  `name` varchar(10) COLLATE utf8mb4_uca1400_ai_ci DEFAULT 'PAGE_CHECKSUM=1'\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_uca1400_ai_ci PAGE_CHECKSUM=1 `PAGE_COMPRESSED`='ON' COMMENT='TRANSACTIONAL=1'"
+	tests := []struct {
+		name    string
+		version string
+		opts    []Option
+		want    string
+	}{
+		{"mariadb", "10.11.6-MariaDB-log", []Option{WithPortableDDL(true)}, "CREATE TABLE `users` (\n" +
+			"  `id` int NOT NULL,\n" +
+			"  `name` varchar(10) DEFAULT 'PAGE_CHECKSUM=1'\n" +
+			") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='TRANSACTIONAL=1';\n"},
+		{"mariadb without the option", "10.11.6-MariaDB-log", nil, create + ";\n"},
+		{"mysql", "8.0.36", []Option{WithPortableDDL(true)}, create + ";\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeDB()
+			f.addUsers()
+			f.set("SELECT version()", []string{"version()"}, fakeRow(test.version))
+			f.set("SHOW CREATE TABLE `db`.`users`", []string{"Table", "Create Table"}, fakeRow("users", create))
+			if got := dumpString(t, f, test.opts...); !strings.Contains(got, test.want) {
+				t.Errorf("dump doesn't have:\n%s\nin:\n%s", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package mysqldump
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Records the server's binary log position in the dump header as a commented
+// CHANGE MASTER TO statement, like mysqldump's --master-data=2, for setting up
+// a replica from the dump. Reading the position takes the lock of
+// WithFlushLock, so it matches the data. Needs binary logging enabled, and
+// WithChangeMaster when WithComments is off.
+func WithMasterData(enabled bool) Option {
+	return func(d *Dumper) {
+		d.masterData = enabled
+	}
+}
+
+// Writes the CHANGE MASTER TO statement of WithMasterData uncommented, like
+// mysqldump's --master-data=1, so restoring the dump on a replica also sets
+// its replication position.
+func WithChangeMaster(enabled bool) Option {
+	return func(d *Dumper) {
+		d.changeMaster = enabled
+	}
+}
+
+// Returns the current binary log file and position.
+func getMasterStatus(ctx context.Context, db Querier) (string, string, error) {
+	rows, err := db.QueryContext(ctx, "SHOW MASTER STATUS")
+	if err != nil {
+		return "", "", err
+	}
+	defer rows.Close()
+
+	// Newer servers return more columns, so pick them by name
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", "", err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", "", err
+		}
+		return "", "", errors.New("Binary logging is not enabled")
+	}
+	values := make([]sql.NullString, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i, _ := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return "", "", err
+	}
+
+	var file, position string
+	for i, column := range columns {
+		switch column {
+		case "File":
+			file = values[i].String
+		case "Position":
+			position = values[i].String
+		}
+	}
+	if file == "" || position == "" {
+		return "", "", errors.New("Binary log position not found")
+	}
+	return file, position, nil
+}
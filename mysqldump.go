@@ -1,16 +1,117 @@
 package mysqldump
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Subset of *sql.DB, *sql.Conn and *sql.Tx used to read from the database.
+// Dumps read through a single connection so session settings and snapshots
+// apply to every query.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+var (
+	_ Querier = (*sql.DB)(nil)
+	_ Querier = (*sql.Conn)(nil)
+	_ Querier = (*sql.Tx)(nil)
 )
 
 // Dumper represents a database.
 type Dumper struct {
-	db     *sql.DB
+	db     Querier
 	format string
 	dir    string
+
+	charset        string
+	compressor     Compressor
+	encryptionKey  []byte
+	maxPacket      int
+	extendedInsert bool
+	insertType     InsertType
+	dialect        Dialect
+	upsert         bool
+	columnNames    bool
+	where          map[string]string
+	excludeColumns map[string][]string
+	includeTables  []string
+	excludeTables  []string
+	noData         bool
+	noCreateInfo   bool
+	dropTable      bool
+	disableChecks  bool
+	triggers       bool
+	routines       bool
+	events         bool
+
+	databases          []string
+	allDatabases       bool
+	createDatabase     bool
+	createIfNotExists  bool
+	truncate           bool
+	disableKeys        bool
+	orderByPrimaryKey  bool
+	dependencyOrder    bool
+	columnTypeComments bool
+	noQuoteNames       bool
+	noComments         bool
+	headerComments     []string
+	checksums          bool
+	rowLimit           int
+	keyRanges          map[string]int
+	incrementalColumns []incrementalColumn
+	since              time.Time
+	skipNonIncremental bool
+	insertBatchRows    int
+	consistentSnapshot bool
+	lockTables         bool
+	lockTablesSet      bool
+	flushLock          bool
+	skipLockErrors     bool
+	continueOnError    bool
+	masterData         bool
+	changeMaster       bool
+	serverVersion      string
+	serverVersionSet   bool
+	stripDefiners      bool
+	tableRename        func(string) string
+	autoIncrement      AutoIncrement
+	tableOptions       map[string]string
+	portableDDL        bool
+	outputFormat       OutputFormat
+	retryAttempts      int
+	retryBackoff       time.Duration
+	timeout            time.Duration
+	rateLimit          int
+	limiter            *rateLimiter
+	transform          func(table, column string, value []byte, null bool) ([]byte, bool)
+	concurrency        int
+	filePerTable       bool
+	archive            bool
+	resume             bool
+	maxFileSize        int64
+	uniqueSuffix       bool
+	fileMode           os.FileMode
+
+	template   *template.Template
+	now        func() time.Time
+	timeFormat string
+	logger     *slog.Logger
+	progress   func(ProgressEvent)
+	progressMu sync.Mutex
 }
 
 /*
@@ -19,28 +120,88 @@ Creates a new dumper.
 	db: Database that will be dumped (https://golang.org/pkg/database/sql/#DB).
 	dir: Path to the directory where the dumps will be stored.
 	format: Format to be used to name each dump file. Uses time.Time.Format (https://golang.org/pkg/time/#Time.Format). format appended with '.sql'.
+	opts: Optional settings, see the With* functions.
+
+Fails if the database can't be reached, the directory isn't writable or the
+format doesn't give a plain file name.
+*/
+func Register(db *sql.DB, dir, format string, opts ...Option) (*Dumper, error) {
+	if db == nil {
+		return nil, errors.New("Invalid database")
+	}
+	return RegisterQuerier(db, dir, format, opts...)
+}
+
+/*
+Creates a new dumper reading through q, such as a *sql.Conn holding session
+state the dump needs or a *sql.Tx whose snapshot it should see. The dump's
+session settings, such as SET NAMES, are applied to q, and q is left open by
+Close unless it is a *sql.DB. Other than with a *sql.DB, WithConcurrency can't
+be used as every query runs on q, and WithConsistentSnapshot can't be used
+within a *sql.Tx.
+
+See Register for the other arguments.
 */
-func Register(db *sql.DB, dir, format string) (*Dumper, error) {
+func RegisterQuerier(q Querier, dir, format string, opts ...Option) (*Dumper, error) {
+	if q == nil {
+		return nil, errors.New("Invalid database")
+	}
 	if !isDir(dir) {
 		return nil, errors.New("Invalid directory")
 	}
+	if !isWritable(dir) {
+		return nil, errors.New("Directory is not writable")
+	}
+	if !isFormat(format) {
+		return nil, errors.New("Invalid format")
+	}
 
-	return &Dumper{
-		db:     db,
+	d := &Dumper{
+		db:     q,
 		format: format,
 		dir:    dir,
-	}, nil
+
+		charset:        defaultCharset,
+		maxPacket:      defaultMaxPacket,
+		extendedInsert: true,
+		dialect:        DialectMySQL,
+		fileMode:       0600,
+		dropTable:      true,
+		disableChecks:  true,
+		now:            time.Now,
+		timeFormat:     time.RFC3339,
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if !d.lockTablesSet {
+		d.lockTables = !d.consistentSnapshot
+	}
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+	if p, ok := q.(interface{ PingContext(context.Context) error }); ok {
+		if err := p.PingContext(context.Background()); err != nil {
+			return nil, fmt.Errorf("connecting to database: %w", err)
+		}
+	}
+
+	return d, nil
 }
 
 // Closes the dumper.
-// Will also close the database the dumper is connected to.
+// Will also close the database the dumper is connected to, if a *sql.DB.
 //
 // Not required.
 func (d *Dumper) Close() error {
 	defer func() {
 		d.db = nil
 	}()
-	return d.db.Close()
+	if db, ok := d.db.(*sql.DB); ok {
+		return db.Close()
+	}
+	return nil
 }
 
 func exists(p string) (bool, os.FileInfo) {
@@ -56,6 +217,24 @@ func exists(p string) (bool, os.FileInfo) {
 	return true, fi
 }
 
+// Moves the finished dump at oldpath to newpath, failing with fs.ErrExist
+// instead of replacing a file created there since the name was picked. The
+// dump is linked to its new name, so taking the name can't race; directories
+// and filesystems without hard links fall back to checking before renaming.
+func renameNew(oldpath, newpath string) error {
+	err := os.Link(oldpath, newpath)
+	if err == nil {
+		return os.Remove(oldpath)
+	}
+	if errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	if e, _ := exists(newpath); e {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrExist}
+	}
+	return os.Rename(oldpath, newpath)
+}
+
 func isFile(p string) bool {
 	if e, fi := exists(p); e {
 		return fi.Mode().IsRegular()
@@ -63,6 +242,33 @@ func isFile(p string) bool {
 	return false
 }
 
+// Reports whether files can be created in dir.
+func isWritable(dir string) bool {
+	f, err := os.CreateTemp(dir, ".mysqldump-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
+
+// Creates or truncates the file at p with the mode from WithFileMode.
+func (d *Dumper) createFile(p string) (*os.File, error) {
+	return os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, d.fileMode)
+}
+
+// Mode for dump directories, searchable by whoever can read the files.
+func (d *Dumper) dirMode() os.FileMode {
+	return d.fileMode | d.fileMode&0444>>2
+}
+
+// Reports whether format gives a usable file name, without any directories.
+func isFormat(format string) bool {
+	name := time.Now().Format(format)
+	return format != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
 func isDir(p string) bool {
 	if e, fi := exists(p); e {
 		return fi.Mode().IsDir()
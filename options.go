@@ -0,0 +1,600 @@
+package mysqldump
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Default character set for reading and restoring dumps, see WithCharset.
+const defaultCharset = "utf8mb4"
+
+// Default limit on the size of a single INSERT statement, see WithMaxPacket.
+const defaultMaxPacket = 1 << 20
+
+// Option configures optional behaviour of a Dumper. Options are passed to Register.
+type Option func(*Dumper)
+
+// Sets the character set used to read the data and declared with SET NAMES
+// at the top of the dump, so multibyte text restores unchanged.
+// Defaults to utf8mb4.
+func WithCharset(charset string) Option {
+	return func(d *Dumper) {
+		d.charset = charset
+	}
+}
+
+// Compresses dumps with gzip at the given level (see compress/gzip).
+// Dump files are named with '.sql.gz' instead of '.sql'.
+// Same as WithCompressor(Gzip(level)).
+func WithCompression(level int) Option {
+	return WithCompressor(Gzip(level))
+}
+
+// Limits the size in bytes of each INSERT statement so a restore stays under
+// the server's max_allowed_packet. Rows are split across multiple INSERT
+// statements as needed. Defaults to 1MB.
+func WithMaxPacket(bytes int) Option {
+	return func(d *Dumper) {
+		d.maxPacket = bytes
+	}
+}
+
+// Writes multiple rows per INSERT statement. On by default; turn it off to
+// get one INSERT per row, which is easier to diff.
+func WithExtendedInsert(enabled bool) Option {
+	return func(d *Dumper) {
+		d.extendedInsert = enabled
+	}
+}
+
+// Starts a new INSERT after every n rows with extended inserts, for importers
+// that apply a statement at a time. Statements still end early at the max
+// packet size. Zero, the default, has no limit.
+func WithInsertBatchRows(n int) Option {
+	return func(d *Dumper) {
+		d.insertBatchRows = n
+	}
+}
+
+// Statement used to insert rows, see WithInsertType.
+type InsertType int
+
+const (
+	InsertPlain  InsertType = iota // INSERT INTO, fails on duplicate keys
+	InsertIgnore                   // INSERT IGNORE INTO, skips rows with duplicate keys
+	Replace                        // REPLACE INTO, overwrites rows with duplicate keys
+)
+
+func (i InsertType) keyword() string {
+	switch i {
+	case InsertIgnore:
+		return "INSERT IGNORE INTO"
+	case Replace:
+		return "REPLACE INTO"
+	}
+	return "INSERT INTO"
+}
+
+// Sets the statement used to insert rows, so a dump can be loaded into a
+// database that already has some of them. Defaults to InsertPlain.
+func WithInsertType(insertType InsertType) Option {
+	return func(d *Dumper) {
+		d.insertType = insertType
+	}
+}
+
+// Ends every INSERT with ON DUPLICATE KEY UPDATE for the columns outside the
+// primary key, so reloading a dump updates existing rows in place.
+// Only valid with InsertPlain.
+func WithUpsert(enabled bool) Option {
+	return func(d *Dumper) {
+		d.upsert = enabled
+	}
+}
+
+// Lists the column names in every INSERT statement so rows restore correctly
+// even if the target table's columns are in a different order.
+func WithColumnNames(enabled bool) Option {
+	return func(d *Dumper) {
+		d.columnNames = enabled
+	}
+}
+
+// Writes the descriptive comments of the default template, such as the
+// server version, table headings and completion time. On by default; turn it
+// off for a dump of only statements, such as for reproducible diffs. Custom
+// templates are not affected.
+func WithComments(enabled bool) Option {
+	return func(d *Dumper) {
+		d.noComments = !enabled
+	}
+}
+
+// Writes lines as comments after the header comments of the dump, such as
+// to record the job or commit that made it. Lines with line breaks become
+// several comment lines and other control characters become spaces, so a
+// line can't end its comment. Written also without WithComments.
+func WithHeaderComment(lines ...string) Option {
+	return func(d *Dumper) {
+		for _, line := range lines {
+			d.headerComments = append(d.headerComments, commentLines(line)...)
+		}
+	}
+}
+
+// Splits text into lines safe to write after "-- ".
+func commentLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.FieldsFunc(text, func(r rune) bool {
+		return r == '\n' || r == '\r'
+	})
+	for i, line := range lines {
+		lines[i] = strings.Map(func(r rune) rune {
+			if r < 0x20 || r == 0x7f {
+				return ' '
+			}
+			return r
+		}, line)
+	}
+	return lines
+}
+
+// Writes a comment before each table's rows listing its columns and their
+// types, as "-- columns: id INT, name VARCHAR(255)", for reading dumps by hand
+// or with other tools.
+func WithColumnTypeComments(enabled bool) Option {
+	return func(d *Dumper) {
+		d.columnTypeComments = enabled
+	}
+}
+
+// Only dumps the rows of table matching condition, like mysqldump's --where.
+// condition is inserted as is after WHERE. Other tables are dumped in full.
+func WithWhere(table, condition string) Option {
+	return func(d *Dumper) {
+		if d.where == nil {
+			d.where = make(map[string]string)
+		}
+		d.where[table] = condition
+	}
+}
+
+// Leaves the values of columns of table out of the dump, such as columns
+// that must not be backed up. INSERTs then list the other columns, and the
+// columns get their defaults when restored. The table's structure still has
+// them.
+func WithExcludeColumns(table string, columns ...string) Option {
+	return func(d *Dumper) {
+		if d.excludeColumns == nil {
+			d.excludeColumns = make(map[string][]string)
+		}
+		d.excludeColumns[table] = append(d.excludeColumns[table], columns...)
+	}
+}
+
+// Dumps rows in primary key order, like mysqldump's --order-by-primary, so
+// dumps of unchanged tables are identical. Tables without a primary key are
+// dumped in whatever order the server returns.
+func WithOrderByPrimaryKey(enabled bool) Option {
+	return func(d *Dumper) {
+		d.orderByPrimaryKey = enabled
+	}
+}
+
+// Dumps at most n rows of each table, for sampling. Combine with
+// WithOrderByPrimaryKey to get the same rows every time. 0 means no limit.
+func WithRowLimit(n int) Option {
+	return func(d *Dumper) {
+		d.rowLimit = n
+	}
+}
+
+// Only dumps tables matching one of the given names. Names may be glob
+// patterns as understood by path.Match, e.g. 'log_*'.
+func WithIncludeTables(tables ...string) Option {
+	return func(d *Dumper) {
+		d.includeTables = append(d.includeTables, tables...)
+	}
+}
+
+// Skips tables matching one of the given names or glob patterns.
+// Takes precedence over WithIncludeTables.
+func WithExcludeTables(tables ...string) Option {
+	return func(d *Dumper) {
+		d.excludeTables = append(d.excludeTables, tables...)
+	}
+}
+
+// Dumps table structure only, without any rows, like mysqldump's --no-data.
+func WithNoData(enabled bool) Option {
+	return func(d *Dumper) {
+		d.noData = enabled
+	}
+}
+
+// Dumps table data only, without DROP or CREATE statements, like mysqldump's
+// --no-create-info. Useful to reload rows into an existing schema.
+func WithNoCreateInfo(enabled bool) Option {
+	return func(d *Dumper) {
+		d.noCreateInfo = enabled
+	}
+}
+
+// Takes a global read lock with FLUSH TABLES WITH READ LOCK while reading,
+// which gives a consistent dump of non-transactional tables such as MyISAM
+// but blocks writes to the whole server. With WithConsistentSnapshot the lock
+// is only held until the snapshot has started. Needs the RELOAD privilege.
+func WithFlushLock(enabled bool) Option {
+	return func(d *Dumper) {
+		d.flushLock = enabled
+	}
+}
+
+// Calls transform for every value dumped, such as to mask personal data.
+// It is called with the table, the column, the value as text, or as bytes for
+// binary columns, and whether it is NULL, and returns the value to dump and
+// whether that is NULL. value is only valid during the call. Numeric values
+// that are changed are written quoted.
+func WithValueTransformer(transform func(table, column string, value []byte, null bool) ([]byte, bool)) Option {
+	return func(d *Dumper) {
+		d.transform = transform
+	}
+}
+
+// Wraps the rows of each table in LOCK TABLES and UNLOCK TABLES, which speeds
+// up restoring MyISAM tables but needs the LOCK TABLES privilege. On by
+// default unless WithConsistentSnapshot is used.
+func WithLockTables(enabled bool) Option {
+	return func(d *Dumper) {
+		d.lockTables = enabled
+		d.lockTablesSet = true
+	}
+}
+
+// Writes version as the server version in the header instead of the one
+// the server reports, such as to not disclose it in dumps shared outside.
+// The server's version is still used to detect MariaDB.
+func WithServerVersion(version string) Option {
+	return func(d *Dumper) {
+		d.serverVersion = strings.NewReplacer("\n", " ", "\r", " ").Replace(version)
+		d.serverVersionSet = true
+	}
+}
+
+// Writes DROP TABLE IF EXISTS before each CREATE TABLE. On by default; turn
+// it off to restore into a database without dropping its existing tables,
+// like mysqldump's --skip-add-drop-table.
+func WithDropTable(enabled bool) Option {
+	return func(d *Dumper) {
+		d.dropTable = enabled
+	}
+}
+
+// Wraps each table's rows in ALTER TABLE ... DISABLE KEYS and ENABLE KEYS, so
+// restoring builds the non-unique indexes of MyISAM tables once at the end
+// instead of row by row. Tables without non-unique indexes are left as is.
+func WithDisableKeys(enabled bool) Option {
+	return func(d *Dumper) {
+		d.disableKeys = enabled
+	}
+}
+
+// Writes TRUNCATE TABLE before each table's rows instead of DROP TABLE, with
+// CREATE TABLE IF NOT EXISTS for the structure, so restoring keeps existing
+// table definitions but replaces their rows.
+func WithTruncate(enabled bool) Option {
+	return func(d *Dumper) {
+		d.truncate = enabled
+	}
+}
+
+// Writes CREATE TABLE IF NOT EXISTS instead of CREATE TABLE, so restoring
+// keeps tables that already exist instead of failing. Usually combined with
+// WithDropTable(false).
+func WithCreateIfNotExists(enabled bool) Option {
+	return func(d *Dumper) {
+		d.createIfNotExists = enabled
+	}
+}
+
+// Reads all tables from a single connection inside a transaction started
+// WITH CONSISTENT SNAPSHOT, like mysqldump's --single-transaction. Gives a
+// consistent dump of InnoDB tables without locking them. LOCK TABLES is left
+// out of the dump unless WithLockTables says otherwise.
+func WithConsistentSnapshot(enabled bool) Option {
+	return func(d *Dumper) {
+		d.consistentSnapshot = enabled
+	}
+}
+
+// Turns foreign key and unique checks off for the duration of a restore, so
+// tables can be loaded in any order and bulk inserts run faster. On by default.
+func WithDisableChecks(enabled bool) Option {
+	return func(d *Dumper) {
+		d.disableChecks = enabled
+	}
+}
+
+// Dumps each table's triggers after its data, like mysqldump's --triggers.
+func WithTriggers(enabled bool) Option {
+	return func(d *Dumper) {
+		d.triggers = enabled
+	}
+}
+
+// Dumps the stored procedures and functions of the database after its
+// tables and views, like mysqldump's --routines.
+func WithRoutines(enabled bool) Option {
+	return func(d *Dumper) {
+		d.routines = enabled
+	}
+}
+
+// Dumps the scheduled events of the database, like mysqldump's --events.
+func WithEvents(enabled bool) Option {
+	return func(d *Dumper) {
+		d.events = enabled
+	}
+}
+
+// Reads up to n tables at a time, each on its own connection. Tables are
+// still written in order, but are held in memory until their turn comes.
+// Cannot be combined with WithConsistentSnapshot, which needs a single connection.
+func WithConcurrency(n int) Option {
+	return func(d *Dumper) {
+		d.concurrency = n
+	}
+}
+
+// Stops each dump that takes longer than timeout, failing it with
+// context.DeadlineExceeded, for callers that don't pass a context. Applies
+// along with the deadline of any context passed. Zero, the default, is no
+// timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(d *Dumper) {
+		d.timeout = timeout
+	}
+}
+
+// Logs the progress of each dump and any errors to logger.
+// Nothing is logged by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(d *Dumper) {
+		if logger != nil {
+			d.logger = logger
+		}
+	}
+}
+
+/*
+Renders dumps with a custom template instead of the default one.
+
+t must define the "header", "table" and "footer" templates, and may define
+"database", "skipped", "view", "routines" and "events". Any of these it leaves
+out are rendered with the default template.
+
+"header" and "footer" are run once each with:
+
+	.DumpVersion: Version of this package.
+	.ServerVersion: Version of the MYSQL server, see WithServerVersion.
+	.Charset: Character set the dump is written in, see WithCharset.
+	.DisableChecks: Whether foreign key and unique checks are turned off, see WithDisableChecks.
+	.NoBackslashEscapes: Whether the server's sql_mode has NO_BACKSLASH_ESCAPES.
+	.Comments: Lines to write as comments, see WithHeaderComment.
+	.MasterLogFile, .MasterLogPos: Binary log position of the dump, see WithMasterData.
+	.ChangeMaster: Whether the position is written as an executable statement.
+	.CompleteTime: Time the dump completed in UTC, see WithTimeFormat. Only set for "footer".
+	.Checksums: Row count and checksum of each table, see WithChecksums. Only set for "footer".
+
+"table" is run for each table with:
+
+	.Name: Name of the table.
+	.NameEsc: Name of the table quoted for use in SQL.
+	.SQL: CREATE TABLE statement, without a trailing ';'.
+	.CreateInfo: Whether the table structure is dumped, see WithNoCreateInfo.
+	.DropTable: Whether DROP TABLE is written before the structure, see WithDropTable.
+	.LockTables: Whether the rows are wrapped in LOCK TABLES, see WithLockTables.
+	.Truncate: Whether TRUNCATE TABLE is written before the rows, see WithTruncate.
+	.DisableKeys: Whether the rows are wrapped in DISABLE and ENABLE KEYS, see WithDisableKeys.
+	.HasValues: Whether the table has any rows.
+	.ColumnTypes: Names and types of the columns, see WithColumnTypeComments.
+	.Stream: Channel of INSERT statements for the rows. Must be ranged over in full when HasValues is true,
+	or the dump fails.
+	.Triggers: CREATE TRIGGER statements for the table, see WithTriggers.
+
+"skipped" is run for each table skipped by WithContinueOnError with .Name and
+.Error, the error on a single line.
+
+"database" and "view" are run for each database and view with .Name, .NameEsc
+and .SQL, the CREATE statement. "routines" and
+"events" are run with a list of items, each with .Type, .Name, .NameEsc and .SQL.
+*/
+func WithTemplate(t *template.Template) Option {
+	return func(d *Dumper) {
+		d.template = t
+	}
+}
+
+// Creates dump files with mode, before the umask. Defaults to 0600, so only
+// the owner can read dumps as they may hold sensitive data. Directories of
+// dumps with WithFilePerTable can also be searched by whoever can read them.
+// The owner must be able to read and write the files.
+func WithFileMode(mode os.FileMode) Option {
+	return func(d *Dumper) {
+		d.fileMode = mode
+	}
+}
+
+// Adds '-1', '-2' and so on to the name of a dump when one with the same name
+// already exists, instead of failing. Useful when the format can give the same
+// name to several dumps, such as one with minute precision.
+func WithUniqueSuffix(enabled bool) Option {
+	return func(d *Dumper) {
+		d.uniqueSuffix = enabled
+	}
+}
+
+// Sets the clock used to name dump files and for the completion time in the
+// footer. Defaults to time.Now; a fixed clock gives reproducible dumps.
+func WithClock(now func() time.Time) Option {
+	return func(d *Dumper) {
+		d.now = now
+	}
+}
+
+// Sets the layout, as for time.Time.Format, of the completion time in the
+// footer. The time is always written in UTC. Defaults to time.RFC3339.
+func WithTimeFormat(layout string) Option {
+	return func(d *Dumper) {
+		d.timeFormat = layout
+	}
+}
+
+func (d *Dumper) validate() error {
+	if !isName(d.charset) {
+		return errors.New("Invalid charset")
+	}
+	if gz, ok := d.compressor.(gzipCompressor); ok && (gz.level < gzip.HuffmanOnly || gz.level > gzip.BestCompression) {
+		return errors.New("Invalid compression level")
+	}
+	if z, ok := d.compressor.(zstdCompressor); ok && z.newWriter == nil {
+		return errors.New("Invalid zstd writer")
+	}
+	if d.encryptionKey != nil {
+		if _, err := newAEAD(d.encryptionKey); err != nil {
+			return errors.New("Invalid encryption key")
+		}
+	}
+	if d.now == nil {
+		return errors.New("Invalid clock")
+	}
+	if d.timeFormat == "" {
+		return errors.New("Invalid time format")
+	}
+	if d.template != nil {
+		for _, name := range requiredTemplates {
+			if d.template.Lookup(name) == nil {
+				return errors.New("Template does not define '" + name + "'")
+			}
+		}
+	}
+	if d.concurrency < 0 {
+		return errors.New("Invalid concurrency")
+	}
+	if d.concurrency > 1 && d.consistentSnapshot {
+		return errors.New("Concurrency cannot be used with a consistent snapshot")
+	}
+	if _, ok := d.db.(*sql.DB); d.concurrency > 1 && !ok {
+		return errors.New("Concurrency needs a *sql.DB to open connections")
+	}
+	if _, ok := d.db.(*sql.Tx); ok && d.consistentSnapshot {
+		return errors.New("Consistent snapshot cannot be used within a transaction")
+	}
+	if d.rateLimit < 0 {
+		return errors.New("Invalid rate limit")
+	}
+	if d.timeout < 0 {
+		return errors.New("Invalid timeout")
+	}
+	if !d.dialect.valid() {
+		return errors.New("Invalid dialect")
+	}
+	if d.insertBatchRows < 0 {
+		return errors.New("Invalid insert batch rows")
+	}
+	if d.rowLimit < 0 {
+		return errors.New("Invalid row limit")
+	}
+	if d.insertType < InsertPlain || d.insertType > Replace {
+		return errors.New("Invalid insert type")
+	}
+	if d.autoIncrement < AutoIncrementPreserve || d.autoIncrement > AutoIncrementStrip {
+		return errors.New("Invalid auto increment mode")
+	}
+	if d.upsert && d.insertType != InsertPlain {
+		return errors.New("Upsert cannot be used with INSERT IGNORE or REPLACE")
+	}
+	if d.tableRename != nil && d.triggers {
+		return errors.New("Table rename cannot be used with triggers")
+	}
+	if d.outputFormat < FormatSQL || d.outputFormat > FormatCSV {
+		return errors.New("Invalid output format")
+	}
+	if d.maxFileSize > 0 && d.rowsOnly() {
+		return errors.New("Max file size can only be used with SQL output")
+	}
+	if d.checksums && d.rowsOnly() {
+		return errors.New("Checksums can only be used with SQL output")
+	}
+	if d.outputFormat == FormatCSV && !d.filePerTable {
+		return errors.New("CSV output can only be used with one file per table")
+	}
+	if !d.validateTableOptions() {
+		return errors.New("Invalid table option")
+	}
+	if !d.validateKeyRanges() {
+		return errors.New("Invalid primary key ranges")
+	}
+	if !d.validateIncrementalColumns() {
+		return errors.New("Invalid incremental column")
+	}
+	if d.retryAttempts < 0 || d.retryBackoff < 0 {
+		return errors.New("Invalid retry")
+	}
+	// A deadlock rolls back the snapshot's transaction, so a retry would read newer data
+	if d.retryAttempts > 1 && d.consistentSnapshot {
+		return errors.New("Retry cannot be used with a consistent snapshot")
+	}
+	// Files are read back, such as into an archive, and directories need the write bit
+	if d.fileMode&^os.ModePerm != 0 || d.fileMode&0600 != 0600 {
+		return errors.New("Invalid file mode")
+	}
+	if d.maxPacket <= 0 {
+		return errors.New("Invalid max packet size")
+	}
+	if d.maxFileSize < 0 {
+		return errors.New("Invalid max file size")
+	}
+	if d.archive && !d.filePerTable {
+		return errors.New("Archive can only be used with one file per table")
+	}
+	if d.resume && (!d.filePerTable || d.archive) {
+		return errors.New("Resume can only be used with one file per table and no archive")
+	}
+	// The default template writes the position as a comment unless it is a statement
+	if d.masterData && !d.changeMaster && d.noComments && d.template == nil {
+		return errors.New("Master data without CHANGE MASTER needs comments")
+	}
+	if d.maxFileSize > 0 && d.filePerTable {
+		return errors.New("Max file size cannot be used with one file per table")
+	}
+	for _, patterns := range [][]string{d.includeTables, d.excludeTables} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return errors.New("Invalid table pattern '" + pattern + "'")
+			}
+		}
+	}
+	return nil
+}
+
+// Reports whether s is a plain name such as a character set, which can be
+// safely used unquoted in SQL.
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
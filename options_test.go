@@ -0,0 +1,242 @@
+package mysqldump
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestRegister(t *testing.T) {
+	dir := t.TempDir()
+	file := path.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(newFakeDB())
+	tests := []struct {
+		name   string
+		db     *sql.DB
+		dir    string
+		format string
+		want   string
+	}{
+		{"no database", nil, dir, "dump", "Invalid database"},
+		{"missing directory", db, path.Join(dir, "missing"), "dump", "Invalid directory"},
+		{"file as directory", db, file, "dump", "Invalid directory"},
+		{"empty format", db, dir, "", "Invalid format"},
+		{"format with directory", db, dir, "a/b", "Invalid format"},
+		{"parent format", db, dir, "..", "Invalid format"},
+		{"valid", db, dir, "2006-01-02", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Register(test.db, test.dir, test.format)
+			if got := errString(err); got != test.want {
+				t.Errorf("Register = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRegisterPing(t *testing.T) {
+	f := newFakeDB()
+	db := sql.OpenDB(failingConnector{f})
+	if _, err := Register(db, t.TempDir(), "dump"); err == nil || !strings.HasPrefix(err.Error(), "connecting to database") {
+		t.Errorf("Register = %v, want connecting error", err)
+	}
+}
+
+func TestRegisterQuerier(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	db := sql.OpenDB(f)
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	for _, q := range []Querier{conn, tx} {
+		f.mu.Lock()
+		f.conns = 0
+		f.mu.Unlock()
+		d, err := RegisterQuerier(q, t.TempDir(), "dump", WithClock(func() time.Time { return fakeNow }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b strings.Builder
+		if err := d.DumpTo(&b); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != wantDump {
+			t.Errorf("DumpTo through %T wrote:\n%s\nwant:\n%s", q, got, wantDump)
+		}
+		if err := d.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// Every query ran on q, which stays open
+		if f.conns != 0 {
+			t.Errorf("DumpTo through %T opened %d connections", q, f.conns)
+		}
+		if err := q.QueryRowContext(context.Background(), "SELECT version()").Scan(new(string)); err != nil {
+			t.Errorf("%T closed with the Dumper: %v", q, err)
+		}
+	}
+
+	if _, err := RegisterQuerier(conn, t.TempDir(), "dump", WithConcurrency(2)); err == nil || err.Error() != "Concurrency needs a *sql.DB to open connections" {
+		t.Errorf("RegisterQuerier with concurrency = %v", err)
+	}
+	if _, err := RegisterQuerier(tx, t.TempDir(), "dump", WithConsistentSnapshot(true)); err == nil || err.Error() != "Consistent snapshot cannot be used within a transaction" {
+		t.Errorf("RegisterQuerier with a snapshot in a transaction = %v", err)
+	}
+}
+
+// A connector that can't connect.
+type failingConnector struct {
+	*fakeDB
+}
+
+func (failingConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("unreachable")
+}
+
+func TestValidate(t *testing.T) {
+	withoutFooter := template.Must(template.New("t").Parse(`{{ define "header" }}{{ end }}{{ define "table" }}{{ end }}`))
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"defaults", nil, ""},
+		{"charset", []Option{WithCharset("utf8; DROP")}, "Invalid charset"},
+		{"compression level", []Option{WithCompression(42)}, "Invalid compression level"},
+		{"zstd writer", []Option{WithCompressor(Zstd(nil))}, "Invalid zstd writer"},
+		{"encryption key", []Option{WithEncryption([]byte("short"))}, "Invalid encryption key"},
+		{"clock", []Option{WithClock(nil)}, "Invalid clock"},
+		{"time format", []Option{WithTimeFormat("")}, "Invalid time format"},
+		{"template", []Option{WithTemplate(withoutFooter)}, "Template does not define 'footer'"},
+		{"concurrency", []Option{WithConcurrency(-1)}, "Invalid concurrency"},
+		{"concurrency with snapshot", []Option{WithConcurrency(2), WithConsistentSnapshot(true)}, "Concurrency cannot be used with a consistent snapshot"},
+		{"rate limit", []Option{WithRateLimit(-1)}, "Invalid rate limit"},
+		{"timeout", []Option{WithTimeout(-time.Second)}, "Invalid timeout"},
+		{"dialect", []Option{WithDialect(Dialect{})}, "Invalid dialect"},
+		{"insert batch rows", []Option{WithInsertBatchRows(-1)}, "Invalid insert batch rows"},
+		{"row limit", []Option{WithRowLimit(-1)}, "Invalid row limit"},
+		{"insert type", []Option{WithInsertType(InsertType(7))}, "Invalid insert type"},
+		{"auto increment", []Option{WithAutoIncrement(AutoIncrement(7))}, "Invalid auto increment mode"},
+		{"upsert with replace", []Option{WithUpsert(true), WithInsertType(Replace)}, "Upsert cannot be used with INSERT IGNORE or REPLACE"},
+		{"table rename with triggers", []Option{WithTableRename(strings.ToUpper), WithTriggers(true)}, "Table rename cannot be used with triggers"},
+		{"output format", []Option{WithOutputFormat(OutputFormat(7))}, "Invalid output format"},
+		{"max file size with json", []Option{WithMaxFileSize(1 << 20), WithOutputFormat(FormatJSON)}, "Max file size can only be used with SQL output"},
+		{"checksums with json", []Option{WithChecksums(true), WithOutputFormat(FormatJSON)}, "Checksums can only be used with SQL output"},
+		{"csv", []Option{WithOutputFormat(FormatCSV)}, "CSV output can only be used with one file per table"},
+		{"csv file per table", []Option{WithOutputFormat(FormatCSV), WithFilePerTable(true)}, ""},
+		{"table option name", []Option{WithTableOption("ROW_FORMAT", "DYNAMIC")}, "Invalid table option"},
+		{"table option value", []Option{WithTableOption("ENGINE", "InnoDB; DROP")}, "Invalid table option"},
+		{"key ranges", []Option{WithPrimaryKeyRanges("users", 0)}, "Invalid primary key ranges"},
+		{"incremental column", []Option{WithIncrementalColumn("users", "")}, "Invalid incremental column"},
+		{"incremental pattern", []Option{WithIncrementalColumn("[", "updated_at")}, "Invalid incremental column"},
+		{"retry", []Option{WithRetry(3, -time.Second)}, "Invalid retry"},
+		{"retry with snapshot", []Option{WithRetry(3, time.Second), WithConsistentSnapshot(true)}, "Retry cannot be used with a consistent snapshot"},
+		{"single attempt with snapshot", []Option{WithRetry(1, time.Second), WithConsistentSnapshot(true)}, ""},
+		{"file mode", []Option{WithFileMode(os.ModeDir | 0700)}, "Invalid file mode"},
+		{"file mode without permissions", []Option{WithFileMode(0)}, "Invalid file mode"},
+		{"file mode not writable", []Option{WithFileMode(0444)}, "Invalid file mode"},
+		{"file mode for group", []Option{WithFileMode(0640)}, ""},
+		{"max packet", []Option{WithMaxPacket(0)}, "Invalid max packet size"},
+		{"max file size", []Option{WithMaxFileSize(-1)}, "Invalid max file size"},
+		{"archive", []Option{WithArchive(true)}, "Archive can only be used with one file per table"},
+		{"master data without comments", []Option{WithMasterData(true), WithComments(false)}, "Master data without CHANGE MASTER needs comments"},
+		{"change master without comments", []Option{WithMasterData(true), WithChangeMaster(true), WithComments(false)}, ""},
+		{"resume", []Option{WithResume(true)}, "Resume can only be used with one file per table and no archive"},
+		{"resume archive", []Option{WithResume(true), WithFilePerTable(true), WithArchive(true)}, "Resume can only be used with one file per table and no archive"},
+		{"resume file per table", []Option{WithResume(true), WithFilePerTable(true)}, ""},
+		{"max file size per table", []Option{WithMaxFileSize(1 << 20), WithFilePerTable(true)}, "Max file size cannot be used with one file per table"},
+		{"table pattern", []Option{WithIncludeTables("[")}, "Invalid table pattern '['"},
+		{"exclude pattern", []Option{WithExcludeTables("a[")}, "Invalid table pattern 'a['"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Register(sql.OpenDB(newFakeDB()), t.TempDir(), "dump", test.opts...)
+			if got := errString(err); got != test.want {
+				t.Errorf("Register = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCommentLines(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"one", []string{"one"}},
+		{"one\r\ntwo\rthree\n", []string{"one", "two", "three"}},
+		{"tab\there\x7f", []string{"tab here "}},
+		{"\n\n", nil},
+	}
+	for _, test := range tests {
+		if got := commentLines(test.text); strings.Join(got, "|") != strings.Join(test.want, "|") {
+			t.Errorf("commentLines(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestIsName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"utf8mb4": true,
+		"latin1":  true,
+		"":        false,
+		"utf8 ":   false,
+		"a;b":     false,
+		"ü":       false,
+	} {
+		if got := isName(name); got != want {
+			t.Errorf("isName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestWithLogger(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	f.addTable("other", []string{"id"}, []string{"INT"})
+	f.fail("SHOW CREATE TABLE `db`.`other`", errors.New("connection lost"))
+	logs := &logRecorder{}
+	var b strings.Builder
+	if err := newTestDumper(t, f, WithLogger(slog.New(logs))).DumpTo(&b); err == nil {
+		t.Fatal("DumpTo succeeded")
+	}
+	want := []string{
+		"INFO starting dump databases=1",
+		"INFO dumping database database=db tables=2",
+		"DEBUG dumping table database=db table=users",
+		"INFO dumped table database=db table=users rows=2",
+		"DEBUG dumping table database=db table=other",
+		"ERROR dumping table failed database=db table=other",
+		"ERROR dump failed",
+	}
+	if got := logs.lines("databases", "database", "tables", "table", "rows"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("logged:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
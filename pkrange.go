@@ -0,0 +1,150 @@
+package mysqldump
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+)
+
+// Splits the rows of table into parts by ranges of its primary key, which
+// must be a single integer column. Each range is written as its own INSERT
+// statements after a '-- Range' comment, so they can be split apart (see
+// StatementScanner) and restored in parallel. The ranges divide the keys
+// between the smallest and largest evenly, the first and last are open
+// ended, so together they hold every row exactly once.
+func WithPrimaryKeyRanges(table string, parts int) Option {
+	return func(d *Dumper) {
+		if d.keyRanges == nil {
+			d.keyRanges = make(map[string]int)
+		}
+		d.keyRanges[table] = parts
+	}
+}
+
+func (d *Dumper) validateKeyRanges() bool {
+	for _, parts := range d.keyRanges {
+		if parts < 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// Primary key values from low to high, where the first range has no low
+// and the last no high bound.
+type keyRange struct {
+	low, high         int64
+	openLow, openHigh bool
+}
+
+// Condition selecting the rows of a range of column.
+func (r keyRange) condition(column string) string {
+	column = quoteIdentifier(column)
+	low, high := strconv.FormatInt(r.low, 10), strconv.FormatInt(r.high, 10)
+	switch {
+	case r.openLow && r.openHigh:
+		return "1=1"
+	case r.openLow:
+		return column + " <= " + high
+	case r.openHigh:
+		return column + " >= " + low
+	}
+	return column + " BETWEEN " + low + " AND " + high
+}
+
+// Reads the smallest and largest primary key of a table and divides them
+// into parts ranges. An empty table has no ranges.
+func (t *table) keyRanges(ctx context.Context, db Querier, parts int) ([]keyRange, error) {
+	if len(t.primaryKey) != 1 {
+		return nil, errors.New("Primary key ranges need a single column primary key")
+	}
+	column := quoteIdentifier(t.primaryKey[0])
+	var min, max *int64
+	err := db.QueryRowContext(ctx, "SELECT MIN("+column+"), MAX("+column+") FROM "+qualify(t.schema, t.Name)).Scan(&min, &max)
+	if err != nil || min == nil || max == nil {
+		return nil, err
+	}
+	return splitKeyRange(*min, *max, parts), nil
+}
+
+// Divides the keys from min to max into at most parts contiguous ranges.
+func splitKeyRange(min, max int64, parts int) []keyRange {
+	// Offsets from min are unsigned so the full range of int64 doesn't overflow
+	span := uint64(max - min)
+	step := span/uint64(parts) + 1
+	if parts == 1 || step == 0 {
+		return []keyRange{{low: min, high: max, openLow: true, openHigh: true}}
+	}
+
+	var ranges []keyRange
+	for offset := uint64(0); ; offset += step {
+		r := keyRange{low: min + int64(offset), openLow: offset == 0}
+		if span-offset < step {
+			r.high, r.openHigh = max, true
+			return append(ranges, r)
+		}
+		r.high = r.low + int64(step-1)
+		ranges = append(ranges, r)
+	}
+}
+
+// Comment written before the rows of the range at index.
+func (t *table) appendRangeComment(b []byte, index int) []byte {
+	if t.d.noComments || len(t.ranges) == 0 {
+		return b
+	}
+	b = append(b, "-- Range "...)
+	b = strconv.AppendInt(b, int64(index+1), 10)
+	b = append(b, " of "...)
+	b = strconv.AppendInt(b, int64(len(t.ranges)), 10)
+	b = append(b, ": "...)
+	b = append(b, t.ranges[index].condition(t.primaryKey[0])...)
+	return append(b, '\n')
+}
+
+// Reads the rows of a table range by range, for its stream only. Each
+// range's rows are closed once the cursor moves past them.
+type rangeCursor struct {
+	t     *table
+	rows  *sql.Rows
+	index int // Range of rows
+	read  int // Rows read so far, see WithRowLimit
+}
+
+// Moves to the next row, going on to the next range with rows once the
+// current one is done. Reports whether there is a row and whether it starts
+// a new range. The row limit applies to all ranges together.
+func (c *rangeCursor) next() (bool, bool, error) {
+	c.read++
+	if c.rows.Next() {
+		return true, false, nil
+	}
+	if err := c.rows.Err(); err != nil {
+		return false, false, err
+	}
+	limit := c.t.d.rowLimit
+	for c.index+1 < len(c.t.ranges) && (limit == 0 || c.read < limit) {
+		c.rows.Close()
+		c.index++
+		remaining := 0
+		if limit > 0 {
+			remaining = limit - c.read
+		}
+		rows, err := c.t.queryValues(c.t.db, c.index, remaining)
+		if err != nil {
+			return false, false, err
+		}
+		if rows != nil {
+			c.rows = rows
+			return true, true, nil
+		}
+	}
+	return false, false, nil
+}
+
+func (c *rangeCursor) close() {
+	if c.rows != nil {
+		c.rows.Close()
+	}
+}
@@ -0,0 +1,150 @@
+package mysqldump
+
+import (
+	"database/sql/driver"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSplitKeyRange(t *testing.T) {
+	tests := []struct {
+		min, max int64
+		parts    int
+		want     string
+	}{
+		{1, 100, 1, "1=1"},
+		{1, 100, 4, "`id` <= 25|`id` BETWEEN 26 AND 50|`id` BETWEEN 51 AND 75|`id` >= 76"},
+		{-3, 21, 3, "`id` <= 5|`id` BETWEEN 6 AND 14|`id` >= 15"},
+		{5, 5, 4, "1=1"},
+		{1, 3, 10, "`id` <= 1|`id` BETWEEN 2 AND 2|`id` >= 3"},
+		{math.MinInt64, math.MaxInt64, 2, "`id` <= -1|`id` >= 0"},
+		{math.MinInt64, math.MaxInt64, 1, "1=1"},
+	}
+	for _, test := range tests {
+		ranges := splitKeyRange(test.min, test.max, test.parts)
+		conditions := make([]string, len(ranges))
+		for i, r := range ranges {
+			conditions[i] = r.condition("id")
+		}
+		if got := strings.Join(conditions, "|"); got != test.want {
+			t.Errorf("splitKeyRange(%d, %d, %d) = %s, want %s", test.min, test.max, test.parts, got, test.want)
+		}
+	}
+}
+
+var rangeCondition = regexp.MustCompile("`id` (<=|>=|BETWEEN) (-?[0-9]+)(?: AND ([0-9]+))?")
+
+// Answers the queries of a table 'ranged' with ids 1 to 20, except 6 to 10,
+// applying the range conditions and limits.
+func addRangedTable(f *fakeDB) {
+	var all [][]driver.Value
+	for id := int64(1); id <= 20; id++ {
+		if id < 6 || id > 10 {
+			all = append(all, fakeRow(id))
+		}
+	}
+	f.addTable("ranged", []string{"id"}, []string{"INT"}, all...)
+	f.setPrimaryKey("ranged", "id")
+	f.set("SELECT MIN(`id`), MAX(`id`) FROM `db`.`ranged`", []string{"min", "max"}, fakeRow(int64(1), int64(20)))
+	f.handle = func(query string, args []driver.Value) (fakeResult, bool) {
+		match := rangeCondition.FindStringSubmatch(query)
+		if !strings.HasPrefix(query, "SELECT `id` FROM") || match == nil {
+			return fakeResult{}, false
+		}
+		low, high := int64(math.MinInt64), int64(math.MaxInt64)
+		bound, _ := strconv.ParseInt(match[2], 10, 64)
+		switch match[1] {
+		case "<=":
+			high = bound
+		case ">=":
+			low = bound
+		default:
+			low = bound
+			high, _ = strconv.ParseInt(match[3], 10, 64)
+		}
+		r := fakeResult{columns: []string{"id"}, types: []string{"INT"}}
+		for _, row := range all {
+			if id := row[0].(int64); id >= low && id <= high {
+				r.rows = append(r.rows, row)
+			}
+		}
+		if match := queryLimit.FindStringSubmatch(query); match != nil {
+			limit, _ := strconv.Atoi(match[1])
+			r.rows = r.rows[:min(limit, len(r.rows))]
+		}
+		return r, true
+	}
+}
+
+func TestPrimaryKeyRanges(t *testing.T) {
+	f := newFakeDB()
+	addRangedTable(f)
+	got := dumpString(t, f, WithPrimaryKeyRanges("ranged", 4))
+	want := "LOCK TABLES `ranged` WRITE;\n" +
+		"-- Range 1 of 4: `id` <= 5\n" +
+		"INSERT INTO `ranged` VALUES (1),(2),(3),(4),(5);\n" +
+		"-- Range 3 of 4: `id` BETWEEN 11 AND 15\n" +
+		"INSERT INTO `ranged` VALUES (11),(12),(13),(14),(15);\n" +
+		"-- Range 4 of 4: `id` >= 16\n" +
+		"INSERT INTO `ranged` VALUES (16),(17),(18),(19),(20);\n" +
+		"UNLOCK TABLES;\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("dump is:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPrimaryKeyRangesNeedSingleColumnKey(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	f.setPrimaryKey("users", "id", "name")
+	var b strings.Builder
+	err := newTestDumper(t, f, WithPrimaryKeyRanges("users", 2)).DumpTo(&b)
+	if err == nil || !strings.Contains(err.Error(), "single column primary key") {
+		t.Errorf("DumpTo = %v, want single column primary key", err)
+	}
+}
+
+func TestPrimaryKeyRangesWithRowLimit(t *testing.T) {
+	tests := []struct {
+		limit   int
+		want    string
+		queries []string // Reading the ranges after the first
+	}{
+		{
+			limit: 7,
+			want: "INSERT INTO `ranged` VALUES (1),(2),(3),(4),(5);\n" +
+				"-- Range 3 of 4: `id` BETWEEN 11 AND 15\n" +
+				"INSERT INTO `ranged` VALUES (11),(12);\nUNLOCK TABLES;\n",
+			queries: []string{"`id` BETWEEN 6 AND 10 LIMIT 2", "`id` BETWEEN 11 AND 15 LIMIT 2"},
+		},
+		{
+			limit:   5,
+			want:    "INSERT INTO `ranged` VALUES (1),(2),(3),(4),(5);\nUNLOCK TABLES;\n",
+			queries: nil,
+		},
+		{
+			limit: 3,
+			want:  "INSERT INTO `ranged` VALUES (1),(2),(3);\nUNLOCK TABLES;\n",
+		},
+	}
+	for _, test := range tests {
+		f := newFakeDB()
+		addRangedTable(f)
+		got := dumpString(t, f, WithPrimaryKeyRanges("ranged", 4), WithRowLimit(test.limit))
+		if !strings.Contains(got, test.want) {
+			t.Errorf("WithRowLimit(%d) dump is:\n%s\nwant:\n%s", test.limit, got, test.want)
+		}
+		var queries []string
+		for _, query := range f.queries() {
+			if strings.HasPrefix(query, "SELECT `id` FROM") && !strings.Contains(query, "<=") {
+				queries = append(queries, query[strings.Index(query, "WHERE ")+len("WHERE "):])
+			}
+		}
+		if strings.Join(queries, "|") != strings.Join(test.queries, "|") {
+			t.Errorf("WithRowLimit(%d) read ranges with %q, want %q", test.limit, queries, test.queries)
+		}
+	}
+}
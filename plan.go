@@ -0,0 +1,144 @@
+package mysqldump
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// DumpPlan lists what a dump would contain. See Plan.
+type DumpPlan struct {
+	Items []PlanItem // Tables and views in dump order
+}
+
+// PlanItem is a table or view that would be dumped.
+type PlanItem struct {
+	Database string
+	Name     string
+	View     bool  // Views are dumped as their CREATE VIEW statement only
+	Rows     int64 // Estimated rows, from information_schema. Always 0 for views.
+	Bytes    int64 // Estimated size of the rows, from information_schema. Always 0 for views.
+}
+
+// TableName is a table or view a dump would contain, see ListTables.
+type TableName struct {
+	Database string
+	Name     string
+	View     bool // Views are dumped as their CREATE VIEW statement only
+}
+
+// Lists the tables and views a dump would contain, after the include and
+// exclude filters, without reading any rows. Row counts are the server's
+// estimates, which are approximate for InnoDB tables.
+func (d *Dumper) Plan(ctx context.Context) (DumpPlan, error) {
+	var plan DumpPlan
+	conn, err := d.openConn(ctx)
+	if err != nil {
+		return plan, err
+	}
+	defer conn.Close()
+
+	names, err := d.dumpedNames(ctx, conn)
+	if err != nil {
+		return plan, err
+	}
+	sizes := make(map[string]map[string]tableSize)
+	for _, name := range names {
+		item := PlanItem{Database: name.Database, Name: name.Name, View: name.View}
+		if !name.View && !d.noData {
+			if _, ok := sizes[name.Database]; !ok {
+				if sizes[name.Database], err = getTableSizes(ctx, conn, name.Database); err != nil {
+					return plan, fmt.Errorf("estimating rows: %w", err)
+				}
+			}
+			item.Rows = sizes[name.Database][name.Name].rows
+			item.Bytes = sizes[name.Database][name.Name].bytes
+		}
+		plan.Items = append(plan.Items, item)
+	}
+	return plan, nil
+}
+
+// Lists the tables and views a dump would contain, after the include and
+// exclude filters and in dump order. Each database's tables are followed by
+// its views.
+func (d *Dumper) ListTables(ctx context.Context) ([]TableName, error) {
+	conn, err := d.openConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return d.dumpedNames(ctx, conn)
+}
+
+// Lists the tables and views of each database dumped, in dump order.
+func (d *Dumper) dumpedNames(ctx context.Context, q Querier) ([]TableName, error) {
+	schemas, err := d.getDatabases(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("listing databases: %w", err)
+	}
+	names := make([]TableName, 0)
+	for _, schema := range schemas {
+		tables, err := d.dumpedTables(ctx, q, schema)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range tables {
+			names = append(names, TableName{Database: schema, Name: name})
+		}
+
+		// Views are only dumped with the table structure
+		if d.noCreateInfo || d.rowsOnly() {
+			continue
+		}
+		views, err := getViews(ctx, q, schema)
+		if err != nil {
+			return nil, fmt.Errorf("listing views: %w", err)
+		}
+		for _, name := range d.filterTables(views) {
+			names = append(names, TableName{Database: schema, Name: name, View: true})
+		}
+	}
+	return names, nil
+}
+
+// Returns the sum of the estimated sizes of the tables a dump would contain.
+// Only reads the server's table statistics, which leave out indexes as they
+// aren't dumped. The SQL in a dump adds to the size, and compression takes
+// from it.
+func (d *Dumper) EstimateSize(ctx context.Context) (int64, error) {
+	plan, err := d.Plan(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, item := range plan.Items {
+		size += item.Bytes
+	}
+	return size, nil
+}
+
+type tableSize struct {
+	rows  int64
+	bytes int64
+}
+
+// Returns the estimated row count and data size of each table in schema.
+func getTableSizes(ctx context.Context, db Querier, schema string) (map[string]tableSize, error) {
+	rows, err := db.QueryContext(ctx, "SELECT TABLE_NAME, TABLE_ROWS, DATA_LENGTH FROM information_schema.TABLES WHERE TABLE_SCHEMA = ?", schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sizes := make(map[string]tableSize)
+	for rows.Next() {
+		var name string
+		var count, length sql.NullInt64
+		if err := rows.Scan(&name, &count, &length); err != nil {
+			return nil, err
+		}
+		sizes[name] = tableSize{rows: count.Int64, bytes: length.Int64}
+	}
+	return sizes, rows.Err()
+}
@@ -0,0 +1,92 @@
+package mysqldump
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+)
+
+// Adds the view 'active' to the database 'db' and the database 'shop'.
+func addViewAndShop(f *fakeDB) {
+	f.set("SHOW FULL TABLES FROM `db` WHERE Table_type = 'VIEW'", []string{"Tables_in_db", "Table_type"},
+		fakeRow("active", "VIEW"))
+	addShop(f)
+}
+
+func TestListTables(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"databases", []Option{WithDatabases("db", "shop")}, "[{db users false} {db active true} {shop orders false}]"},
+		{"exclude", []Option{WithDatabases("db", "shop"), WithExcludeTables("act*", "orders")}, "[{db users false}]"},
+		{"no create info", []Option{WithNoCreateInfo(true)}, "[{db users false}]"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeDB()
+			f.addUsers()
+			addViewAndShop(f)
+			tables, err := newTestDumper(t, f, test.opts...).ListTables(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprint(tables); got != test.want {
+				t.Errorf("ListTables = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+// Sets the server's estimated rows and sizes of the tables of 'db' and 'shop'.
+func setTableSizes(f *fakeDB) {
+	query := "SELECT TABLE_NAME, TABLE_ROWS, DATA_LENGTH FROM information_schema.TABLES WHERE TABLE_SCHEMA = ?"
+	columns := []string{"TABLE_NAME", "TABLE_ROWS", "DATA_LENGTH"}
+	f.set(fmt.Sprint(query, []driver.Value{"db"}), columns,
+		fakeRow("users", int64(2), int64(16384)), fakeRow("active", nil, nil))
+	f.set(fmt.Sprint(query, []driver.Value{"shop"}), columns, fakeRow("orders", int64(1), int64(8192)))
+}
+
+func TestPlan(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	addViewAndShop(f)
+	setTableSizes(f)
+	plan, err := newTestDumper(t, f, WithDatabases("db", "shop")).Plan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[{db users false 2 16384} {db active true 0 0} {shop orders false 1 8192}]"
+	if got := fmt.Sprint(plan.Items); got != want {
+		t.Errorf("Plan = %s, want %s", got, want)
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want int64
+	}{
+		{"databases", []Option{WithDatabases("db", "shop")}, 16384 + 8192},
+		{"exclude", []Option{WithDatabases("db", "shop"), WithExcludeTables("orders")}, 16384},
+		{"no data", []Option{WithDatabases("db", "shop"), WithNoData(true)}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeDB()
+			f.addUsers()
+			addViewAndShop(f)
+			setTableSizes(f)
+			size, err := newTestDumper(t, f, test.opts...).EstimateSize(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != test.want {
+				t.Errorf("EstimateSize = %d, want %d", size, test.want)
+			}
+		})
+	}
+}
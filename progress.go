@@ -0,0 +1,30 @@
+package mysqldump
+
+// Number of rows streamed between progress events for a table.
+const progressInterval = 1000
+
+// ProgressEvent describes how far a dump has got. See WithProgress.
+type ProgressEvent struct {
+	Database   string // Database of Table
+	Table      string // Table being dumped
+	TableIndex int    // Position of Table in the dump, starting at 0
+	TableCount int    // Number of tables in the dump
+	Rows       int64  // Rows of Table written so far
+}
+
+// Reports progress for dumps of very large databases. fn is called when each
+// table starts and then every 1000 rows, plus once when its rows are done.
+// Calls are sequential but may come from a goroutine other than Dump's.
+func WithProgress(fn func(ProgressEvent)) Option {
+	return func(d *Dumper) {
+		d.progress = fn
+	}
+}
+
+func (d *Dumper) reportProgress(event ProgressEvent) {
+	if d.progress != nil {
+		d.progressMu.Lock()
+		defer d.progressMu.Unlock()
+		d.progress(event)
+	}
+}
@@ -0,0 +1,30 @@
+package mysqldump
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	addLargeTable(f, "large", 2500)
+	f.addTable("empty", []string{"id"}, []string{"INT"})
+	var events []string
+	dumpString(t, f, WithProgress(func(event ProgressEvent) {
+		events = append(events, fmt.Sprintf("%s.%s %d/%d %d", event.Database, event.Table, event.TableIndex, event.TableCount, event.Rows))
+	}))
+	want := []string{
+		"db.users 0/3 0",
+		"db.users 0/3 2",
+		"db.large 1/3 0",
+		"db.large 1/3 1000",
+		"db.large 1/3 2000",
+		"db.large 1/3 2500",
+		"db.empty 2/3 0",
+	}
+	if got := strings.Join(events, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("progress events:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
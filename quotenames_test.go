@@ -0,0 +1,50 @@
+package mysqldump
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNeedsQuotes(t *testing.T) {
+	for name, want := range map[string]bool{
+		"users":      false,
+		"_tmp$1":     false,
+		"order":      true,
+		"Key":        true,
+		"first name": true,
+		"1st":        true,
+		"ü":          true,
+		"":           true,
+	} {
+		if got := needsQuotes(name); got != want {
+			t.Errorf("needsQuotes(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestNoQuoteNamesKeepsRequiredQuotes(t *testing.T) {
+	f := newFakeDB()
+	f.addTable("order items", []string{"id", "key", "first name"}, []string{"INT", "INT", "VARCHAR"}, fakeRow(int64(1), int64(2), "x"))
+	logs := &logRecorder{}
+	got := dumpString(t, f, WithQuoteNames(false), WithColumnNames(true), WithLogger(slog.New(logs)))
+	for _, want := range []string{
+		"LOCK TABLES `order items` WRITE;\n",
+		"INSERT INTO `order items` (id, `key`, `first name`) VALUES (1,2,'x');\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("dump doesn't have %q:\n%s", want, got)
+		}
+	}
+
+	var warnings []string
+	for _, line := range logs.lines("table", "names") {
+		if strings.HasPrefix(line, "WARN") {
+			warnings = append(warnings, line)
+		}
+	}
+	want := "WARN quoting names that require it table=order items names=[order items key first name]"
+	if strings.Join(warnings, "\n") != want {
+		t.Errorf("warned:\n%s\nwant:\n%s", strings.Join(warnings, "\n"), want)
+	}
+}
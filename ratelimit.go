@@ -0,0 +1,57 @@
+package mysqldump
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limits how fast the rows of tables are read to bytesPerSecond, counted as
+// the rows are written before compression, so dumps of a busy server leave
+// it room for other queries. Applies across all tables read at once with
+// WithConcurrency and all dumps of the Dumper. Zero, the default, is no
+// limit.
+func WithRateLimit(bytesPerSecond int) Option {
+	return func(d *Dumper) {
+		d.rateLimit = bytesPerSecond
+		d.limiter = nil
+		if bytesPerSecond > 0 {
+			d.limiter = newRateLimiter(bytesPerSecond)
+		}
+	}
+}
+
+// Token bucket holding up to a second of bytes.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // Bytes per second
+	tokens float64 // Bytes that can be read now, negative when waiting for more
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSecond int) *rateLimiter {
+	return &rateLimiter{rate: float64(bytesPerSecond), last: time.Now()}
+}
+
+// Takes n bytes from the bucket, waiting until they are available or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,22 @@
+package mysqldump
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	f := newFakeDB()
+	addLargeTable(f, "large", 1000)
+	const rate = 200 << 10
+	start := time.Now()
+	got := dumpString(t, f, WithRateLimit(rate))
+	elapsed := time.Since(start)
+
+	// The bucket starts empty, so the rows take at least their size over the rate
+	rows := len(got[strings.Index(got, "INSERT"):strings.Index(got, "UNLOCK TABLES")])
+	if want := time.Duration(float64(rows) / rate * float64(time.Second)); elapsed < want*9/10 {
+		t.Errorf("dump of %d bytes of rows at %d bytes per second took %v, want at least %v", rows, rate, elapsed, want)
+	}
+}
@@ -0,0 +1,49 @@
+package mysqldump
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Matches a foreign key's referenced table, which may be qualified with a database.
+var referencesClause = regexp.MustCompile("REFERENCES `((?:[^`]|``)*)`(\\.`(?:[^`]|``)*`)?")
+
+// Renames tables in the dump, such as to change their prefix when restoring
+// into another schema. rename is called with each table's name and returns
+// the name written in DROP, CREATE, LOCK and INSERT statements and in foreign
+// key references. Tables are still read under their own names. Views and
+// routines are written unchanged, so those using a renamed table must be
+// fixed by hand. Register fails with WithTriggers, as triggers would be
+// created on the tables' old names.
+func WithTableRename(rename func(name string) string) Option {
+	return func(d *Dumper) {
+		d.tableRename = rename
+	}
+}
+
+func (d *Dumper) renameTable(name string) string {
+	if d.tableRename == nil {
+		return name
+	}
+	return d.tableRename(name)
+}
+
+// Applies WithTableRename to the CREATE TABLE statement of table name.
+func (d *Dumper) renameCreate(name, table_sql string) string {
+	if d.tableRename == nil {
+		return table_sql
+	}
+	prefix := "CREATE TABLE " + quoteIdentifier(name)
+	if strings.HasPrefix(table_sql, prefix) {
+		table_sql = "CREATE TABLE " + quoteIdentifier(d.tableRename(name)) + table_sql[len(prefix):]
+	}
+
+	// References to tables in other databases are kept
+	return referencesClause.ReplaceAllStringFunc(table_sql, func(reference string) string {
+		match := referencesClause.FindStringSubmatch(reference)
+		if match[2] != "" {
+			return reference
+		}
+		return "REFERENCES " + quoteIdentifier(d.tableRename(strings.Replace(match[1], "``", "`", -1)))
+	})
+}
@@ -0,0 +1,204 @@
+package mysqldump
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Name of the file in a partial dump directory listing the tables already
+// dumped, see WithResume.
+const checkpointName = ".checkpoint"
+
+// Keeps the '.partial' directory of a dump that fails, with a '.checkpoint'
+// file listing the tables it completed. The next Dump then continues the most
+// recent such dump instead of starting a new one, skipping the completed
+// tables and keeping their files. Only dumps made with the same name format,
+// databases, output format, compression, encryption key and table and column
+// filters are continued. Stats of the continued dump include the
+// skipped tables, except for their bytes. Only dumps with one file per table
+// can be continued: Register fails unless WithFilePerTable is on and
+// WithArchive is off.
+func WithResume(enabled bool) Option {
+	return func(d *Dumper) {
+		d.resume = enabled
+	}
+}
+
+// Settings a partial dump must have been made with to be continued, written
+// as the first line of its checkpoint.
+type dumpIdentity struct {
+	Format         string              `json:"format"`
+	Databases      []string            `json:"databases,omitempty"`
+	AllDatabases   bool                `json:"allDatabases,omitempty"`
+	OutputFormat   OutputFormat        `json:"outputFormat"`
+	Compression    string              `json:"compression,omitempty"`   // Extension of the compressor
+	EncryptionKey  string              `json:"encryptionKey,omitempty"` // SHA-256 of the key
+	IncludeTables  []string            `json:"includeTables,omitempty"`
+	ExcludeTables  []string            `json:"excludeTables,omitempty"`
+	Where          map[string]string   `json:"where,omitempty"`
+	ExcludeColumns map[string][]string `json:"excludeColumns,omitempty"`
+}
+
+// Returns the identity line of the dumps d makes, see dumpIdentity.
+func (d *Dumper) identity() ([]byte, error) {
+	identity := dumpIdentity{
+		Format:         d.format,
+		Databases:      d.databases,
+		AllDatabases:   d.allDatabases,
+		OutputFormat:   d.outputFormat,
+		IncludeTables:  d.includeTables,
+		ExcludeTables:  d.excludeTables,
+		Where:          d.where,
+		ExcludeColumns: d.excludeColumns,
+	}
+	if d.compressor != nil {
+		identity.Compression = d.compressor.Extension()
+	}
+	if d.encryptionKey != nil {
+		sum := sha256.Sum256(d.encryptionKey)
+		identity.EncryptionKey = hex.EncodeToString(sum[:])
+	}
+	return json.Marshal(identity)
+}
+
+// Returns the name of the most recent partial dump that can be continued,
+// and the table files it completed. The name is empty when there is none.
+// Partial dumps made with other settings are left alone, see dumpIdentity.
+func (d *Dumper) findCheckpoint() (string, []manifestFile, error) {
+	identity, err := d.identity()
+	if err != nil {
+		return "", nil, err
+	}
+	checkpoints, err := filepath.Glob(path.Join(d.dir, "*.partial", checkpointName))
+	if err != nil {
+		return "", nil, err
+	}
+	var latest string
+	var latestInfo fs.FileInfo
+	var latestFiles []manifestFile
+	for _, checkpoint := range checkpoints {
+		info, err := os.Stat(checkpoint)
+		if err != nil {
+			return "", nil, err
+		}
+		if latestInfo != nil && !info.ModTime().After(latestInfo.ModTime()) {
+			continue
+		}
+		files, ok, err := readCheckpoint(checkpoint, identity)
+		if err != nil {
+			return "", nil, err
+		}
+		if ok {
+			latest, latestInfo, latestFiles = checkpoint, info, files
+		}
+	}
+	if latest == "" {
+		return "", nil, nil
+	}
+	name := strings.TrimSuffix(path.Base(path.Dir(latest)), ".partial")
+	return name, latestFiles, nil
+}
+
+// Returns the table files listed in a checkpoint, and whether it has the
+// given identity line.
+func readCheckpoint(checkpoint string, identity []byte) ([]manifestFile, bool, error) {
+	f, err := os.Open(checkpoint)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() || !bytes.Equal(scanner.Bytes(), identity) {
+		return nil, false, scanner.Err()
+	}
+	var files []manifestFile
+	for scanner.Scan() {
+		var file manifestFile
+		if err := json.Unmarshal(scanner.Bytes(), &file); err != nil {
+			// A line cut short by a crash is the last one, its table is dumped again
+			break
+		}
+		files = append(files, file)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, err
+	}
+	return files, true, nil
+}
+
+// Adds the table files of the dump being continued.
+func (f *tableFiles) resume(files []manifestFile) {
+	f.done = make(map[[2]string]bool, len(files))
+	for _, file := range files {
+		f.files = append(f.files, file)
+		f.done[[2]string{file.Database, file.Table}] = true
+		f.run.addTable(TableStats{Database: file.Database, Table: file.Table, Rows: file.Rows, Checksum: file.Checksum})
+	}
+}
+
+// Leaves out the tables of schema completed by the dump being continued.
+func (f *tableFiles) remaining(schema string, tables []string) []string {
+	if len(f.done) == 0 {
+		return tables
+	}
+	remaining := make([]string, 0, len(tables))
+	for _, name := range tables {
+		if !f.done[[2]string{schema, name}] {
+			remaining = append(remaining, name)
+		}
+	}
+	return remaining
+}
+
+// Records a dumped table in the checkpoint, with WithResume.
+func (r *dumpRun) checkpoint(stats TableStats) error {
+	if r.files == nil || !r.files.d.resume {
+		return nil
+	}
+	var file *manifestFile
+	for i, _ := range r.files.files {
+		if r.files.files[i].Database == stats.Database && r.files.files[i].Table == stats.Table {
+			file = &r.files.files[i]
+		}
+	}
+	if file == nil {
+		return errors.New("Table file not found for checkpoint")
+	}
+	file.Rows, file.Checksum = stats.Rows, stats.Checksum
+	line, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	// The first table written is preceded by the identity of the dump
+	name := path.Join(r.files.dir, checkpointName)
+	if e, _ := exists(name); !e {
+		identity, err := r.files.d.identity()
+		if err != nil {
+			return err
+		}
+		line = append(append(identity, '\n'), line...)
+	}
+	out, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, r.files.d.fileMode)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(line)
+	if serr := out.Sync(); err == nil {
+		err = serr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
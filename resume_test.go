@@ -0,0 +1,111 @@
+package mysqldump
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResume(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	f.addTable("other", []string{"id"}, []string{"INT"}, fakeRow(int64(1)))
+	f.addTable("last", []string{"id"}, []string{"INT"}, fakeRow(int64(1)))
+	f.fail("SHOW CREATE TABLE `db`.`last`", errors.New("connection lost"))
+	d := newTestDumper(t, f, WithFilePerTable(true), WithResume(true), WithChecksums(true))
+	if err := d.Dump(); err == nil {
+		t.Fatal("Dump succeeded")
+	}
+	partial := path.Join(d.dir, "dump.partial")
+	checkpoint, err := os.ReadFile(path.Join(partial, checkpointName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(checkpoint), "\n") - 1; n != 2 {
+		t.Errorf("checkpoint lists %d tables after its identity, want 2:\n%s", n, checkpoint)
+	}
+
+	// The next dump only reads the table that failed
+	f.set("SHOW CREATE TABLE `db`.`last`", []string{"Table", "Create Table"}, fakeRow("last", "CREATE TABLE `last` (\n  `id` int\n)"))
+	f.mu.Lock()
+	f.log = nil
+	f.mu.Unlock()
+	stats, err := d.DumpWithStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.ran("SHOW CREATE TABLE `db`.`users`") || !f.ran("SHOW CREATE TABLE `db`.`last`") {
+		t.Errorf("continued dump ran %q", f.queries())
+	}
+	if len(stats.Tables) != 3 || stats.Rows != 4 {
+		t.Errorf("stats = %+v", stats)
+	}
+	manifest, err := os.ReadFile(path.Join(d.dir, "dump", manifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(manifest), "db.users.sql\ndb.other.sql\ndb.last.sql\ndump.sql\n"; got != want {
+		t.Errorf("manifest is %q, want %q", got, want)
+	}
+	main, err := os.ReadFile(path.Join(d.dir, "dump", "dump.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(main), "-- Checksum ") != 3 {
+		t.Errorf("main file doesn't have every checksum:\n%s", main)
+	}
+	if e, _ := exists(partial); e {
+		t.Error("partial directory left behind")
+	}
+}
+
+func TestResumeWithoutCheckpoint(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	f.fail("SHOW CREATE TABLE `db`.`users`", errors.New("connection lost"))
+	d := newTestDumper(t, f, WithFilePerTable(true), WithResume(true))
+	if err := d.Dump(); err == nil {
+		t.Fatal("Dump succeeded")
+	}
+	if entries, _ := os.ReadDir(d.dir); len(entries) > 0 {
+		t.Errorf("dump without a completed table left %s behind", entries[0].Name())
+	}
+}
+
+func TestResumeOtherDump(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	f.addTable("last", []string{"id"}, []string{"INT"}, fakeRow(int64(1)))
+	f.fail("SHOW CREATE TABLE `db`.`last`", errors.New("connection lost"))
+	d := newTestDumper(t, f, WithFilePerTable(true), WithResume(true))
+	if err := d.Dump(); err == nil {
+		t.Fatal("Dump succeeded")
+	}
+
+	// A dump with other settings starts afresh, keeping the partial dump
+	f.set("SHOW CREATE TABLE `db`.`last`", []string{"Table", "Create Table"}, fakeRow("last", "CREATE TABLE `last` (\n  `id` int\n)"))
+	f.mu.Lock()
+	f.log = nil
+	f.mu.Unlock()
+	other, err := Register(sql.OpenDB(f), d.dir, "other", WithClock(func() time.Time { return fakeNow }),
+		WithFilePerTable(true), WithResume(true), WithWhere("users", "id = 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Dump(); err != nil {
+		t.Fatal(err)
+	}
+	if !f.ran("SHOW CREATE TABLE `db`.`users`") {
+		t.Errorf("dump with other settings continued the partial dump, ran %q", f.queries())
+	}
+	if e, _ := exists(path.Join(d.dir, "other")); !e {
+		t.Error("dump with other settings not written under its own name")
+	}
+	if e, _ := exists(path.Join(d.dir, "dump.partial", checkpointName)); !e {
+		t.Error("partial dump with other settings removed")
+	}
+}
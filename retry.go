@@ -0,0 +1,50 @@
+package mysqldump
+
+import (
+	"context"
+	"regexp"
+	"time"
+)
+
+// Matches lock wait timeouts (1205) and deadlocks (1213) as reported by the
+// go-sql-driver/mysql ("Error 1213") and mymysql ("#1213") drivers.
+var transientError = regexp.MustCompile(`(?:Error |#)(?:1205|1213)\b`)
+
+// Retries reading a table's structure and starting to read its rows up to
+// attempts times in all when the server reports a lock wait timeout or a
+// deadlock, waiting backoff before the first retry and twice as long before
+// each further one. Other errors, and errors once rows are being written,
+// fail the dump immediately. Can't be used with WithConsistentSnapshot, as
+// the server rolls back the snapshot's transaction on a deadlock.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(d *Dumper) {
+		d.retryAttempts = attempts
+		d.retryBackoff = backoff
+	}
+}
+
+func isTransient(err error) bool {
+	return transientError.MatchString(err.Error())
+}
+
+// Calls read for a table until it succeeds, fails with an error that isn't
+// transient or runs out of attempts.
+func (d *Dumper) retry(ctx context.Context, schema, name string, read func() error) error {
+	backoff := d.retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := read()
+		if err == nil || attempt >= d.retryAttempts || !isTransient(err) {
+			return err
+		}
+		d.logger.Warn("retrying table", "database", schema, "table", name, "attempt", attempt, "error", err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,57 @@
+package mysqldump
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsTransient(t *testing.T) {
+	for message, want := range map[string]bool{
+		"Error 1205 (HY000): Lock wait timeout exceeded":     true,
+		"Error 1213: Deadlock found when trying to get lock": true,
+		"#1213 Deadlock found":                               true,
+		"Error 1146: Table doesn't exist":                    false,
+		"Error 12050: something else":                        false,
+	} {
+		if got := isTransient(errors.New(message)); got != want {
+			t.Errorf("isTransient(%q) = %v, want %v", message, got, want)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		failures int
+		err      string
+		want     string
+	}{
+		{"succeeds", 3, 2, "Error 1213: Deadlock", ""},
+		{"runs out of attempts", 3, 3, "Error 1213: Deadlock", "Deadlock"},
+		{"not transient", 3, 1, "Error 1146: No table", "No table"},
+		{"no retries", 0, 1, "Error 1205: Lock wait timeout", "Lock wait timeout"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeDB()
+			f.addUsers()
+			failures := test.failures
+			f.handle = func(query string, args []driver.Value) (fakeResult, bool) {
+				if strings.HasPrefix(query, "SHOW CREATE TABLE") && failures > 0 {
+					failures--
+					return fakeResult{err: errors.New(test.err)}, true
+				}
+				return fakeResult{}, false
+			}
+			var b strings.Builder
+			err := newTestDumper(t, f, WithRetry(test.attempts, time.Millisecond)).DumpTo(&b)
+			if got := errString(err); test.want == "" && got != "" || !strings.Contains(got, test.want) {
+				t.Errorf("DumpTo = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package mysqldump
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"strings"
+)
+
+/*
+Restores a dump created by this package by executing its statements against a database.
+
+	db: Database to restore into (https://golang.org/pkg/database/sql/#DB).
+	r: Reader for the uncompressed dump.
+
+All statements are run on a single connection so session settings and LOCK TABLES
+apply to the whole dump.
+*/
+func Source(db *sql.DB, r io.Reader) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	statements := NewStatementScanner(r)
+	for statements.Scan() {
+		if _, err := conn.ExecContext(ctx, statements.Statement()); err != nil {
+			return err
+		}
+	}
+	return statements.Err()
+}
+
+// StatementScanner splits SQL text into statements on ';', ignoring any
+// inside quoted strings, quoted identifiers and comments. Line comments are
+// dropped and /* */ comments are kept, as they may be executable comments.
+// Like the mysql client, DELIMITER lines change the terminator, and like the
+// server, SET SQL_MODE with NO_BACKSLASH_ESCAPES makes backslashes in strings
+// ordinary characters.
+type StatementScanner struct {
+	r     *bufio.Reader
+	lexer statementLexer
+	b     strings.Builder // Text of the statement being read
+	read  []string        // Statements read but not yet returned
+	stmt  string
+	err   error
+}
+
+// Returns a scanner reading statements from r, such as an uncompressed dump.
+func NewStatementScanner(r io.Reader) *StatementScanner {
+	return &StatementScanner{r: bufio.NewReader(r), lexer: statementLexer{delimiter: ";"}}
+}
+
+// Advances to the next statement, which is then returned by Statement.
+// Returns false at the end of the input or on an error, see Err.
+func (s *StatementScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	s.stmt, s.err = s.next()
+	return s.err == nil
+}
+
+// Returns the statement read by the last call to Scan, without its terminator.
+func (s *StatementScanner) Statement() string {
+	return s.stmt
+}
+
+// Returns the error that stopped Scan, or nil at the end of the input.
+func (s *StatementScanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+// Returns the next statement without its terminator, or io.EOF when there are none left.
+func (s *StatementScanner) next() (string, error) {
+	for len(s.read) == 0 {
+		line, err := s.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if lerr := s.lexer.scanLine(line, s.add); lerr != nil {
+			return "", lerr
+		}
+		if err == io.EOF {
+			if s.lexer.quote != 0 || s.lexer.comment {
+				return "", io.ErrUnexpectedEOF
+			}
+			s.add(nil, true)
+			if len(s.read) == 0 {
+				return "", io.EOF
+			}
+		}
+	}
+	stmt := s.read[0]
+	s.read = s.read[1:]
+	return stmt, nil
+}
+
+// Adds a piece of statement text, ending the statement if end is set.
+func (s *StatementScanner) add(piece []byte, end bool) {
+	s.b.Write(piece)
+	if !end {
+		return
+	}
+	if stmt := strings.TrimSpace(s.b.String()); stmt != "" {
+		s.read = append(s.read, stmt)
+	}
+	s.b.Reset()
+}
+
+// Follows the statements of SQL text line by line, for StatementScanner and
+// partWriter, so both split the text in the same places.
+type statementLexer struct {
+	delimiter string
+	quote     byte   // Quote of the string or identifier being read, if any
+	comment   bool   // Inside a /* */ comment
+	statement bool   // Inside a statement that is not yet terminated
+	noEscapes bool   // Backslashes in strings are ordinary characters, see NO_BACKSLASH_ESCAPES
+	head      []byte // Start of the statement, to find SET SQL_MODE
+}
+
+// Reports whether the text so far ends between statements.
+func (l *statementLexer) between() bool {
+	return l.quote == 0 && !l.comment && !l.statement
+}
+
+// Scans a line, which ends in '\n' unless it is the last of the text. Calls
+// add, if not nil, with each piece of the line that belongs to a statement,
+// with end set when the piece is followed by the terminator. Line comments
+// are replaced by a newline and DELIMITER lines are left out.
+func (l *statementLexer) scanLine(line []byte, add func(piece []byte, end bool)) error {
+	start := 0 // Start of the piece not added yet
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case l.comment:
+			if c == '*' && i+1 < len(line) && line[i+1] == '/' {
+				l.comment = false
+				i++
+			}
+		case l.quote != 0:
+			if c == '\\' && l.quote != '`' && !l.noEscapes {
+				i++
+			} else if c == l.quote {
+				l.quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			l.quote = c
+			l.statement = true
+		case c == '#' || (c == '-' && isLineComment(line[i:])):
+			l.add(add, line[start:i], false)
+			l.add(add, []byte{'\n'}, false)
+			return nil
+		case c == '/' && i+1 < len(line) && line[i+1] == '*':
+			l.comment = true
+			l.statement = true
+			i++
+		case bytes.HasPrefix(line[i:], []byte(l.delimiter)):
+			l.add(add, line[start:i], true)
+			l.statement = false
+			i += len(l.delimiter) - 1
+			start = i + 1
+		case !l.statement && len(line)-i > 10 && strings.EqualFold(string(line[i:i+10]), "DELIMITER "):
+			if l.delimiter = strings.TrimSpace(string(line[i+10:])); l.delimiter == "" {
+				return errors.New("Missing delimiter after DELIMITER")
+			}
+			return nil
+		case c != ' ' && c != '\t' && c != '\r' && c != '\n':
+			l.statement = true
+		}
+	}
+	l.add(add, line[start:], false)
+	return nil
+}
+
+// Passes a piece of statement text to add, following SET SQL_MODE
+// statements on the way, which change how strings are read.
+func (l *statementLexer) add(add func(piece []byte, end bool), piece []byte, end bool) {
+	// Only the start of other statements is kept
+	rest := piece
+	if len(l.head) == 0 {
+		rest = bytes.TrimLeft(rest, " \t\r\n")
+	}
+	if len(l.head) <= 12 {
+		n := min(len(rest), 13-len(l.head))
+		l.head, rest = append(l.head, rest[:n]...), rest[n:]
+	}
+	if isSetSQLMode(string(l.head)) {
+		l.head = append(l.head, rest...)
+	}
+	if end {
+		if isSetSQLMode(string(l.head)) {
+			l.noEscapes = bytes.Contains(bytes.ToUpper(l.head), []byte("NO_BACKSLASH_ESCAPES"))
+		}
+		l.head = l.head[:0]
+	}
+	if add != nil {
+		add(piece, end)
+	}
+}
+
+// Reports whether stmt is a SET SQL_MODE statement.
+func isSetSQLMode(stmt string) bool {
+	return len(stmt) > 12 && strings.EqualFold(stmt[:12], "SET SQL_MODE")
+}
+
+// Reports whether b starts with a '-- ' comment.
+func isLineComment(b []byte) bool {
+	if len(b) < 2 || b[0] != '-' || b[1] != '-' {
+		return false
+	}
+	return len(b) == 2 || b[2] == ' ' || b[2] == '\t' || b[2] == '\r' || b[2] == '\n'
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,134 @@
+package mysqldump
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestStatementScanner(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "statements",
+			input: "SET NAMES utf8mb4;\nSELECT 1;\n\nSELECT 2",
+			want:  []string{"SET NAMES utf8mb4", "SELECT 1", "SELECT 2"},
+		},
+		{
+			name:  "delimiter in strings",
+			input: `INSERT INTO t VALUES ('a;b',"c;d",` + "`e;f`" + `);`,
+			want:  []string{`INSERT INTO t VALUES ('a;b',"c;d",` + "`e;f`" + `)`},
+		},
+		{
+			name:  "escaped quotes",
+			input: `INSERT INTO t VALUES ('it\'s;', 'a''b;');SELECT 1;`,
+			want:  []string{`INSERT INTO t VALUES ('it\'s;', 'a''b;')`, "SELECT 1"},
+		},
+		{
+			name:  "no backslash escapes",
+			input: "SET SQL_MODE='NO_BACKSLASH_ESCAPES';\nINSERT INTO t VALUES ('a\\');\nSELECT 1;",
+			want:  []string{"SET SQL_MODE='NO_BACKSLASH_ESCAPES'", "INSERT INTO t VALUES ('a\\')", "SELECT 1"},
+		},
+		{
+			name:  "comments",
+			input: "-- comment;\n# another;\nSELECT 1; -- trailing\n/*!40101 SET x=1 */;\nSELECT 2--1;",
+			want:  []string{"SELECT 1", "/*!40101 SET x=1 */", "SELECT 2--1"},
+		},
+		{
+			name:  "delimiter",
+			input: "DELIMITER ;;\nCREATE TRIGGER t BEFORE INSERT ON x FOR EACH ROW BEGIN SET @a=1; END;;\nDELIMITER ;\nSELECT 1;",
+			want:  []string{"CREATE TRIGGER t BEFORE INSERT ON x FOR EACH ROW BEGIN SET @a=1; END", "SELECT 1"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewStatementScanner(strings.NewReader(test.input))
+			var got []string
+			for s.Scan() {
+				got = append(got, s.Statement())
+			}
+			if err := s.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, "\n|\n") != strings.Join(test.want, "\n|\n") {
+				t.Errorf("statements are %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStatementScannerErrors(t *testing.T) {
+	for _, input := range []string{"SELECT 'unterminated", "SELECT 1 /* unterminated", "DELIMITER \n"} {
+		s := NewStatementScanner(strings.NewReader(input))
+		for s.Scan() {
+		}
+		if s.Err() == nil {
+			t.Errorf("scanning %q succeeded", input)
+		}
+	}
+}
+
+func TestSourceDump(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	dump := dumpString(t, f, WithTriggers(false))
+
+	target := newFakeDB()
+	if err := Source(sql.OpenDB(target), strings.NewReader(dump)); err != nil {
+		t.Fatal(err)
+	}
+	var inserts []string
+	for _, query := range target.queries() {
+		if strings.HasPrefix(query, "INSERT") {
+			inserts = append(inserts, query)
+		}
+	}
+	if want := "INSERT INTO `users` VALUES (1,'O\\'Brien',0x00ff),(2,NULL,NULL)"; len(inserts) != 1 || inserts[0] != want {
+		t.Errorf("restored %q, want %q", inserts, want)
+	}
+}
+
+func TestSplitDumpStatements(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	s := NewStatementScanner(strings.NewReader(dumpString(t, f)))
+	n := 0
+	for s.Scan() {
+		n++
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	// 10 SET statements, DROP, CREATE, LOCK, INSERT, UNLOCK and 7 SET statements
+	if n != 22 {
+		t.Errorf("dump has %d statements, want 22", n)
+	}
+}
+
+func TestGeometryRoundTrip(t *testing.T) {
+	// POINT(1 2) with SRID 4326 in the server's internal format: the SRID,
+	// then the WKB with its byte order, type and coordinates
+	point, _ := hex.DecodeString("e6100000" + "01" + "01000000" + "000000000000f03f" + "0000000000000040")
+	f := newFakeDB()
+	f.addTable("places", []string{"id", "location"}, []string{"INT", "POINT"}, fakeRow(int64(1), point))
+	dump := dumpString(t, f)
+
+	target := newFakeDB()
+	if err := Source(sql.OpenDB(target), strings.NewReader(dump)); err != nil {
+		t.Fatal(err)
+	}
+	var restored []byte
+	for _, query := range target.queries() {
+		if value, ok := strings.CutPrefix(query, "INSERT INTO `places` VALUES (1,0x"); ok {
+			restored, _ = hex.DecodeString(strings.TrimSuffix(value, ")"))
+		}
+	}
+	if !bytes.Equal(restored, point) {
+		t.Errorf("restored point %x, want %x", restored, point)
+	}
+}
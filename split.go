@@ -0,0 +1,183 @@
+package mysqldump
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// Splits the dump into numbered files of at most bytes each, named
+// '<format>.001.sql', '<format>.002.sql' and so on. Files only end between
+// statements, so a statement larger than the limit gets a file of its own.
+// Concatenating the files gives the whole dump. With compression or
+// encryption the limit applies before them and each file is compressed and
+// encrypted on its own.
+// DumpTo is not affected.
+func WithMaxFileSize(bytes int64) Option {
+	return func(d *Dumper) {
+		d.maxFileSize = bytes
+	}
+}
+
+// Same as DumpContext but split into numbered files, see WithMaxFileSize.
+// Each file is written with a '.partial' suffix, which is removed from all of
+// them once the dump is complete.
+func (d *Dumper) dumpParts(ctx context.Context, name string, run *dumpRun) (err error) {
+	partName := func(n int) string {
+		return fmt.Sprintf("%s.%03d%s", name, n, d.extension())
+	}
+
+	// Pick a name whose first part is free, later parts are opened exclusively as they are needed
+	err = d.claimName(name, func(candidate string) error {
+		name = candidate
+		for _, p := range []string{partName(1), partName(1) + ".partial"} {
+			if e, _ := exists(path.Join(d.dir, p)); e {
+				return fs.ErrExist
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var partials []string
+	w := newPartWriter(d.maxFileSize, func(n int) (io.WriteCloser, error) {
+		p := path.Join(d.dir, partName(n)) + ".partial"
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, d.fileMode)
+		if err != nil {
+			return nil, err
+		}
+		partials = append(partials, p)
+		cw, err := d.encodeWriter(run.count(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &partFile{cw, f}, nil
+	})
+	defer func() {
+		// Remove partially written parts, also when a callback panics
+		r := recover()
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+		var done []string
+		for i := 0; err == nil && r == nil && i < len(partials); i++ {
+			p := strings.TrimSuffix(partials[i], ".partial")
+			if err = renameNew(partials[i], p); err == nil {
+				done = append(done, p)
+			}
+		}
+		if err != nil || r != nil {
+			for _, p := range append(partials, done...) {
+				os.Remove(p)
+			}
+		}
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	return d.dumpTo(ctx, w, run)
+}
+
+// A part file with its compressor, closed together.
+type partFile struct {
+	io.WriteCloser
+	f *os.File
+}
+
+func (p *partFile) Close() error {
+	err := p.WriteCloser.Close()
+	if cerr := p.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Writes a dump across parts of at most max bytes, starting a new part only
+// between statements. Statements are found line by line with the same lexer
+// as Source, including DELIMITER changes.
+type partWriter struct {
+	max    int64
+	create func(n int) (io.WriteCloser, error)
+
+	part    io.WriteCloser
+	parts   int
+	size    int64  // Bytes written to the current part
+	pending []byte // Bytes since the last statement boundary
+	scanned int    // Length of pending already scanned
+	lexer   statementLexer
+}
+
+func newPartWriter(max int64, create func(n int) (io.WriteCloser, error)) *partWriter {
+	return &partWriter{max: max, create: create, lexer: statementLexer{delimiter: ";"}}
+}
+
+func (w *partWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending[w.scanned:], '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := w.lexer.scanLine(w.pending[w.scanned:w.scanned+i+1], nil); err != nil {
+			return 0, err
+		}
+		w.scanned += i + 1
+
+		// Everything up to the end of a statement can go to a part
+		if w.lexer.between() {
+			if err := w.flush(w.scanned); err != nil {
+				return 0, err
+			}
+		}
+	}
+}
+
+// Writes anything left and closes the last part.
+func (w *partWriter) Close() error {
+	err := w.flush(len(w.pending))
+	if w.part != nil {
+		if cerr := w.part.Close(); err == nil {
+			err = cerr
+		}
+		w.part = nil
+	}
+	return err
+}
+
+// Writes the first n pending bytes, starting a new part first if they don't
+// fit in the current one.
+func (w *partWriter) flush(n int) error {
+	if n == 0 {
+		return nil
+	}
+	if w.part != nil && w.size > 0 && w.size+int64(n) > w.max {
+		err := w.part.Close()
+		w.part = nil
+		if err != nil {
+			return err
+		}
+	}
+	if w.part == nil {
+		part, err := w.create(w.parts + 1)
+		if err != nil {
+			return err
+		}
+		w.part, w.size = part, 0
+		w.parts++
+	}
+
+	written, err := w.part.Write(w.pending[:n])
+	w.size += int64(written)
+	w.pending = w.pending[:copy(w.pending, w.pending[n:])]
+	w.scanned -= n
+	return err
+}
@@ -0,0 +1,137 @@
+package mysqldump
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// Collects the parts written by a partWriter.
+type testParts struct {
+	parts []*bytes.Buffer
+}
+
+func (p *testParts) create(n int) (io.WriteCloser, error) {
+	b := &bytes.Buffer{}
+	p.parts = append(p.parts, b)
+	return nopCloser{b}, nil
+}
+
+func TestPartWriter(t *testing.T) {
+	tests := []struct {
+		name  string
+		max   int64
+		input string
+		want  []string
+	}{
+		{
+			name:  "fits",
+			max:   100,
+			input: "SELECT 1;\nSELECT 2;\n",
+			want:  []string{"SELECT 1;\nSELECT 2;\n"},
+		},
+		{
+			name:  "split between statements",
+			max:   12,
+			input: "SELECT 1;\nSELECT 2;\nSELECT 3;\n",
+			want:  []string{"SELECT 1;\n", "SELECT 2;\n", "SELECT 3;\n"},
+		},
+		{
+			name:  "statement over lines",
+			max:   12,
+			input: "INSERT INTO t\nVALUES (1);\nSELECT 1;\n",
+			want:  []string{"INSERT INTO t\nVALUES (1);\n", "SELECT 1;\n"},
+		},
+		{
+			name:  "line break in string",
+			max:   12,
+			input: "SELECT 'a;\nb';\nSELECT 1;\n",
+			want:  []string{"SELECT 'a;\nb';\n", "SELECT 1;\n"},
+		},
+		{
+			name:  "delimiter",
+			max:   20,
+			input: "DELIMITER ;;\nCREATE TRIGGER x;\nSET a=1;;\nDELIMITER ;\n",
+			want:  []string{"DELIMITER ;;\n", "CREATE TRIGGER x;\nSET a=1;;\n", "DELIMITER ;\n"},
+		},
+		{
+			name:  "comment",
+			max:   12,
+			input: "-- a; b\nSELECT 1;\n",
+			want:  []string{"-- a; b\n", "SELECT 1;\n"},
+		},
+		{
+			name:  "no backslash escapes",
+			max:   12,
+			input: "SET SQL_MODE=\n'NO_BACKSLASH_ESCAPES';\nSELECT 'a\\';\nSELECT 1;\n",
+			want:  []string{"SET SQL_MODE=\n'NO_BACKSLASH_ESCAPES';\n", "SELECT 'a\\';\n", "SELECT 1;\n"},
+		},
+		{
+			name:  "block comment",
+			max:   12,
+			input: "/* a;\nb */;\nSELECT 1;\n",
+			want:  []string{"/* a;\nb */;\n", "SELECT 1;\n"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var parts testParts
+			w := newPartWriter(test.max, parts.create)
+			// Written a byte at a time, so statements arrive in pieces
+			for i := 0; i < len(test.input); i++ {
+				if _, err := w.Write([]byte{test.input[i]}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, part := range parts.parts {
+				got = append(got, part.String())
+			}
+			if strings.Join(got, "|") != strings.Join(test.want, "|") {
+				t.Errorf("parts are %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMaxFileSize(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	f.addTable("other", []string{"id"}, []string{"INT"}, fakeRow(int64(1)))
+	d := newTestDumper(t, f, WithMaxFileSize(400))
+	if err := d.Dump(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var whole []byte
+	for i, entry := range entries {
+		if want := "dump.00" + string(rune('1'+i)) + ".sql"; entry.Name() != want {
+			t.Fatalf("file %d is %s, want %s", i, entry.Name(), want)
+		}
+		data, err := os.ReadFile(path.Join(d.dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		whole = append(whole, data...)
+	}
+	if len(entries) < 2 {
+		t.Errorf("dump has %d files", len(entries))
+	}
+
+	var want bytes.Buffer
+	if err := d.DumpTo(&want); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(whole, want.Bytes()) {
+		t.Errorf("parts together are:\n%s\nwant:\n%s", whole, want.Bytes())
+	}
+}
@@ -0,0 +1,86 @@
+package mysqldump
+
+import (
+	"io"
+	"time"
+)
+
+// Stats describes a completed dump. See DumpWithStats.
+type Stats struct {
+	Tables   []TableStats  // Tables dumped, in dump order
+	Rows     int64         // Rows written across all tables
+	Bytes    int64         // Bytes written to the dump files, after compression
+	Duration time.Duration // Time taken by the dump
+	Errors   []TableError  // Tables skipped, see WithContinueOnError
+}
+
+// TableStats describes one dumped table.
+type TableStats struct {
+	Database string
+	Table    string
+	Rows     int64  // Rows written
+	Checksum string // Checksum of the rows, see WithChecksums
+}
+
+// State of a single dump, shared by everything writing it.
+type dumpRun struct {
+	files *tableFiles // Set when each table is written to its own file
+	table string      // Set when dumping only this table
+	data  dump        // Header and footer of the dump
+	stats Stats
+
+	// Databases dumped without LOCK TABLES, see WithSkipLockTablesOnError
+	unlocked []string
+
+	// Version of the server, which the header may not show, see WithServerVersion
+	serverVersion string
+}
+
+// Writes a table rendered by render, to w or to its own file.
+func (r *dumpRun) write(w io.Writer, schema, name string, render func(io.Writer) error) error {
+	if r.files != nil {
+		return r.files.write(schema, name, render)
+	}
+	return render(w)
+}
+
+func (r *dumpRun) isUnlocked(schema string) bool {
+	for _, name := range r.unlocked {
+		if name == schema {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *dumpRun) addTable(stats TableStats) {
+	r.stats.Tables = append(r.stats.Tables, stats)
+	r.stats.Rows += stats.Rows
+}
+
+// Lines for the footer with the checksum of each table, see WithChecksums.
+func (r *dumpRun) checksums() []string {
+	var lines []string
+	for _, table := range r.stats.Tables {
+		if table.Checksum != "" {
+			lines = append(lines, checksumLine(table))
+		}
+	}
+	return lines
+}
+
+// Wraps w to add the bytes written to it to the stats.
+func (r *dumpRun) count(w io.Writer) io.Writer {
+	return &countingWriter{w, &r.stats.Bytes}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,91 @@
+package mysqldump
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Table options WithTableOption can change, with the pattern each is found by.
+var tableOptionPatterns = map[string]*regexp.Regexp{
+	"ENGINE":  regexp.MustCompile(" ENGINE=[0-9A-Za-z_]+"),
+	"CHARSET": regexp.MustCompile(" DEFAULT CHARSET=[0-9A-Za-z_]+"),
+	"COLLATE": regexp.MustCompile(" COLLATE=[0-9A-Za-z_]+"),
+}
+
+var tableOptionValue = regexp.MustCompile("^[0-9A-Za-z_]+$")
+
+// Replaces the ENGINE, CHARSET or COLLATE table option of CREATE TABLE
+// statements with value, or removes it when value is empty, such as to load
+// a dump into a server without the original engine. Only options the
+// statement has are changed. Changing CHARSET also removes COLLATE unless it
+// is changed too, as it belongs to the old character set. Column character
+// sets are kept.
+func WithTableOption(name, value string) Option {
+	return func(d *Dumper) {
+		if d.tableOptions == nil {
+			d.tableOptions = make(map[string]string)
+		}
+		d.tableOptions[strings.ToUpper(name)] = value
+	}
+}
+
+func (d *Dumper) validateTableOptions() bool {
+	for name, value := range d.tableOptions {
+		if tableOptionPatterns[name] == nil || value != "" && !tableOptionValue.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Splits a CREATE TABLE statement around its table options, which follow the
+// closing parenthesis at the start of a line. Columns are indented so their
+// attributes are never part of the options, and the table's COMMENT, which
+// comes last, is left out so its text isn't either.
+func splitTableOptions(table_sql string) (before, options, after string) {
+	start := strings.Index(table_sql, "\n)")
+	if start < 0 {
+		return table_sql, "", ""
+	}
+	end := strings.IndexByte(table_sql[start+1:], '\n')
+	if end < 0 {
+		end = len(table_sql)
+	} else {
+		end += start + 1
+	}
+	if comment := strings.Index(table_sql[start:end], " COMMENT='"); comment >= 0 {
+		end = start + comment
+	}
+	return table_sql[:start], table_sql[start:end], table_sql[end:]
+}
+
+// Applies WithTableOption to a CREATE TABLE statement.
+func (d *Dumper) rewriteTableOptions(table_sql string) string {
+	if len(d.tableOptions) == 0 {
+		return table_sql
+	}
+	before, options, after := splitTableOptions(table_sql)
+
+	changes := d.tableOptions
+	if _, ok := changes["CHARSET"]; ok {
+		if _, ok := changes["COLLATE"]; !ok {
+			changes = map[string]string{"COLLATE": ""}
+			for name, value := range d.tableOptions {
+				changes[name] = value
+			}
+		}
+	}
+	for name, value := range changes {
+		replacement := ""
+		if value != "" {
+			replacement = " " + name + "=" + value
+			if name == "CHARSET" {
+				replacement = " DEFAULT CHARSET=" + value
+			}
+		}
+		if loc := tableOptionPatterns[name].FindStringIndex(options); loc != nil {
+			options = options[:loc[0]] + replacement + options[loc[1]:]
+		}
+	}
+	return before + options + after
+}
@@ -0,0 +1,203 @@
+package mysqldump
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Mismatch is a table whose rows differ from a dump, see Verify.
+type Mismatch struct {
+	Database string
+	Table    string
+	DumpRows int64 // Rows in the dump
+	Rows     int64 // Rows in the database
+}
+
+/*
+Compares the checksums written by WithChecksums to a dump with the tables in
+the database, and returns the tables whose rows differ. The Dumper should have
+the same options as the one that made the dump, so the same rows are read.
+
+	dumpPath: Dump file, gzip compressed or encrypted with the Dumper's key, or
+	the directory of a dump with one file per table. Dumps compressed with
+	other Compressors, such as Zstd, can't be verified.
+*/
+func (d *Dumper) Verify(ctx context.Context, dumpPath string) ([]Mismatch, error) {
+	expected, err := d.readChecksums(dumpPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading checksums: %w", err)
+	}
+	if len(expected) == 0 {
+		return nil, errors.New("No checksums in dump")
+	}
+
+	conn, err := d.openConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var mismatches []Mismatch
+	for _, want := range expected {
+		got, err := d.tableChecksum(ctx, conn, want.Database, want.Table)
+		if err != nil {
+			return nil, fmt.Errorf("checking table %q: %w", want.Table, err)
+		}
+		if got.Rows != want.Rows || got.Checksum != want.Checksum {
+			mismatches = append(mismatches, Mismatch{Database: want.Database, Table: want.Table, DumpRows: want.Rows, Rows: got.Rows})
+		}
+	}
+	return mismatches, nil
+}
+
+// Reads the rows of a table as a dump would and returns their count and checksum.
+func (d *Dumper) tableChecksum(ctx context.Context, q Querier, schema, name string) (TableStats, error) {
+	stats := TableStats{Database: schema, Table: name}
+	t := &table{Name: name, d: d, ctx: ctx, schema: schema}
+	dumped, err := t.readRowsQuery(ctx, q)
+	if err != nil {
+		return stats, err
+	}
+	if dumped && len(t.columns) > 0 {
+		if err = t.openValues(q); err != nil {
+			return stats, err
+		}
+	}
+
+	checksum := newRowChecksum()
+	if t.hasValues {
+		c := &rangeCursor{t: t, rows: t.rows, index: t.rangeIndex}
+		defer c.close()
+		data := make([]sql.RawBytes, len(t.columns))
+		ptrs := make([]interface{}, len(t.columns))
+		for i, _ := range data {
+			ptrs[i] = &data[i]
+		}
+		for ok := true; ok; {
+			if err = c.rows.Scan(ptrs...); err != nil {
+				return stats, err
+			}
+			checksum.add(data)
+			stats.Rows++
+			if ok, _, err = c.next(); err != nil {
+				return stats, err
+			}
+		}
+	}
+	stats.Checksum = checksum.String()
+	return stats, nil
+}
+
+// Reads the "-- Checksum" lines of a dump.
+func (d *Dumper) readChecksums(dumpPath string) ([]TableStats, error) {
+	// The main file of a dump with one file per table is written last, with every checksum
+	if info, err := os.Stat(dumpPath); err == nil && info.IsDir() {
+		manifest, err := os.ReadFile(path.Join(dumpPath, manifestName))
+		if err != nil {
+			return nil, err
+		}
+		files := strings.Fields(string(manifest))
+		if len(files) == 0 {
+			return nil, errors.New("Empty manifest")
+		}
+		dumpPath = path.Join(dumpPath, files[len(files)-1])
+	}
+
+	f, err := os.Open(dumpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := d.decodeReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lines longer than the buffer, such as INSERTs of large rows, are
+	// skipped over in pieces. Only the start of a line is checked.
+	var checksums []TableStats
+	br := bufio.NewReader(r)
+	for start := true; ; {
+		line, err := br.ReadSlice('\n')
+		if rest, ok := bytes.CutPrefix(line, []byte("-- Checksum ")); ok && start && err != bufio.ErrBufferFull {
+			stats, err := parseChecksumLine(string(rest))
+			if err != nil {
+				return nil, err
+			}
+			checksums = append(checksums, stats)
+		}
+		switch err {
+		case nil:
+			start = true
+		case bufio.ErrBufferFull:
+			start = false
+		case io.EOF:
+			return checksums, nil
+		default:
+			return nil, err
+		}
+	}
+}
+
+// Undoes the encryption and gzip compression of a dump, detected from its first
+// bytes. Dumps compressed in other formats can't be decoded.
+func (d *Dumper) decodeReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(encryptionMagic)); string(magic) == encryptionMagic {
+		if d.encryptionKey == nil {
+			return nil, errors.New("Dump is encrypted")
+		}
+		dr, err := DecryptReader(br, d.encryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(dr)
+	}
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return gzip.NewReader(br)
+	}
+	if magic, _ := br.Peek(4); bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+		return nil, errors.New("Zstd compressed dumps can't be read, only gzip")
+	}
+	// Custom compression isn't detected, but the Dumper should match the dump's
+	if _, ok := d.compressor.(gzipCompressor); d.compressor != nil && !ok {
+		return nil, errors.New("Dumps compressed as " + d.compressor.Extension() + " can't be read, only gzip")
+	}
+	return br, nil
+}
+
+// Parses a line written by checksumLine.
+func parseChecksumLine(line string) (TableStats, error) {
+	var stats TableStats
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return stats, errors.New("Invalid checksum line")
+	}
+	schema, name, ok := strings.Cut(fields[0], ".")
+	if !ok {
+		return stats, errors.New("Invalid checksum line")
+	}
+	var err error
+	if stats.Database, err = url.PathUnescape(schema); err != nil {
+		return stats, err
+	}
+	if stats.Table, err = url.PathUnescape(name); err != nil {
+		return stats, err
+	}
+	if stats.Rows, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+		return stats, err
+	}
+	stats.Checksum = fields[2]
+	return stats, nil
+}
@@ -0,0 +1,137 @@
+package mysqldump
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		file string
+	}{
+		{"plain", nil, "dump.sql"},
+		{"gzip", []Option{WithCompression(gzip.BestSpeed)}, "dump.sql.gz"},
+		{"encrypted", []Option{WithEncryption(testKey), WithCompression(gzip.BestSpeed)}, "dump.sql.gz.enc"},
+		{"file per table", []Option{WithFilePerTable(true)}, "dump"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeDB()
+			f.addUsers()
+			f.addTable("other", []string{"id"}, []string{"INT"}, fakeRow(int64(1)))
+			d := newTestDumper(t, f, append([]Option{WithChecksums(true)}, test.opts...)...)
+			if err := d.Dump(); err != nil {
+				t.Fatal(err)
+			}
+			p := path.Join(d.dir, test.file)
+			mismatches, err := d.Verify(context.Background(), p)
+			if err != nil || len(mismatches) > 0 {
+				t.Fatalf("Verify of unchanged tables = %v, %v", mismatches, err)
+			}
+
+			f.setRows("other", []string{"id"}, []string{"INT"}, fakeRow(int64(1)), fakeRow(int64(2)))
+			mismatches, err = d.Verify(context.Background(), p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Mismatch{Database: "db", Table: "other", DumpRows: 1, Rows: 2}
+			if len(mismatches) != 1 || mismatches[0] != want {
+				t.Errorf("Verify = %+v, want %+v", mismatches, want)
+			}
+		})
+	}
+}
+
+func TestVerifyWithoutChecksums(t *testing.T) {
+	f := newFakeDB()
+	f.addUsers()
+	d := newTestDumper(t, f)
+	if err := d.Dump(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := d.Verify(context.Background(), path.Join(d.dir, "dump.sql"))
+	if err == nil || !strings.Contains(err.Error(), "No checksums") {
+		t.Errorf("Verify = %v, want no checksums", err)
+	}
+}
+
+func TestVerifyLargeRow(t *testing.T) {
+	f := newFakeDB()
+	f.addTable("blobs", []string{"id", "data"}, []string{"INT", "LONGBLOB"},
+		fakeRow(int64(1), bytes.Repeat([]byte{0xab}, 700<<10)))
+	d := newTestDumper(t, f, WithChecksums(true))
+	if err := d.Dump(); err != nil {
+		t.Fatal(err)
+	}
+	mismatches, err := d.Verify(context.Background(), path.Join(d.dir, "dump.sql"))
+	if err != nil || len(mismatches) > 0 {
+		t.Errorf("Verify = %v, %v", mismatches, err)
+	}
+}
+
+func TestVerifyUnsupportedCompression(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Compressor
+		data string // Start of the dump file
+		want string
+	}{
+		{"zstd", Zstd(markCompressor{}.Wrap), "\x28\xb5\x2f\xfd", "Zstd"},
+		{"custom", markCompressor{}, "<--", "compressed as .mark"},
+	}
+	for _, test := range tests {
+		f := newFakeDB()
+		d := newTestDumper(t, f, WithCompressor(test.c))
+		p := path.Join(d.dir, "dump.sql")
+		if err := os.WriteFile(p, []byte(test.data+"-- Checksum db.users 2 00ff00ff00ff00ff\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := d.Verify(context.Background(), p)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Verify of %s dump = %v, want %s", test.name, err, test.want)
+		}
+	}
+}
+
+func TestVerifyReadsRowsAsDumped(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		query string // Reading the rows, the same in the dump and Verify
+	}{
+		{"order by primary key", []Option{WithOrderByPrimaryKey(true)},
+			"SELECT `id` FROM `db`.`ranged` ORDER BY `id`"},
+		{"key ranges with row limit", []Option{WithPrimaryKeyRanges("ranged", 4), WithRowLimit(7)},
+			"SELECT `id` FROM `db`.`ranged` WHERE `id` BETWEEN 11 AND 15 LIMIT 2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newFakeDB()
+			addRangedTable(f)
+			d := newTestDumper(t, f, append([]Option{WithChecksums(true)}, test.opts...)...)
+			if err := d.Dump(); err != nil {
+				t.Fatal(err)
+			}
+			if !f.ran(test.query) {
+				t.Fatalf("dump didn't read the rows with %q, ran %q", test.query, f.queries())
+			}
+			f.mu.Lock()
+			f.log = nil
+			f.mu.Unlock()
+			mismatches, err := d.Verify(context.Background(), path.Join(d.dir, "dump.sql"))
+			if err != nil || len(mismatches) > 0 {
+				t.Errorf("Verify = %v, %v", mismatches, err)
+			}
+			if !f.ran(test.query) {
+				t.Errorf("Verify didn't read the rows with %q, ran %q", test.query, f.queries())
+			}
+		})
+	}
+}